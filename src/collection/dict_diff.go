@@ -0,0 +1,283 @@
+package collection
+
+import (
+	"fmt"
+	"iter"
+	"reflect"
+
+	"github.com/ArborDB/arbordb/src/core"
+	"github.com/ArborDB/arbordb/src/dshash"
+	"github.com/ArborDB/arbordb/src/scalar"
+)
+
+type DictChangeKind int
+
+const (
+	DictAdded DictChangeKind = iota + 1
+	DictRemoved
+	DictChanged
+)
+
+// DictChange is a change of a key between two Dicts. Old is the zero value
+// for added keys and New is the zero value for removed keys.
+type DictChange[K interface {
+	comparable
+	core.Expression
+}, V core.Expression] struct {
+	Kind DictChangeKind
+	Key  K
+	Old  V
+	New  V
+}
+
+var _ core.Expression = DictChange[scalar.String, scalar.Int]{}
+
+func (c DictChange[K, V]) String() string {
+	switch c.Kind {
+	case DictAdded:
+		return fmt.Sprintf("+%v: %v", c.Key, c.New)
+	case DictRemoved:
+		return fmt.Sprintf("-%v: %v", c.Key, c.Old)
+	}
+	return fmt.Sprintf("~%v: %v -> %v", c.Key, c.Old, c.New)
+}
+
+// DiffDict returns the keys added, removed or changed from a to b, in no
+// particular order. Values are compared with dshash.Equal.
+//
+// When a and b are DictSet and DictRemove layers over a common Dict, or
+// DictRefs to it, only the keys of the layers above it are compared. Otherwise both are scanned,
+// skipping values shared by identity.
+func DiffDict[K interface {
+	comparable
+	core.Expression
+}, V core.Expression](ctx *core.Context, a Dict[K, V], b Dict[K, V]) iter.Seq2[DictChange[K, V], error] {
+	return func(yield func(DictChange[K, V], error) bool) {
+		if sameDict(a, b) {
+			return
+		}
+
+		if keys, ok := layerKeys(a, b); ok {
+			for _, key := range keys {
+				change, changed, err := diffKey(ctx, a, b, key)
+				if err != nil {
+					yield(DictChange[K, V]{}, err)
+					return
+				}
+				if changed && !yield(change, nil) {
+					return
+				}
+			}
+			return
+		}
+
+		old := make(map[K]V)
+		for kv, err := range a.IterDict(ctx) {
+			if err != nil {
+				yield(DictChange[K, V]{}, err)
+				return
+			}
+			old[kv.Key] = kv.Value
+		}
+		for kv, err := range b.IterDict(ctx) {
+			if err != nil {
+				yield(DictChange[K, V]{}, err)
+				return
+			}
+			oldValue, ok := old[kv.Key]
+			if !ok {
+				if !yield(DictChange[K, V]{
+					Kind: DictAdded,
+					Key:  kv.Key,
+					New:  kv.Value,
+				}, nil) {
+					return
+				}
+				continue
+			}
+			delete(old, kv.Key)
+			equal, err := equalValues(oldValue, kv.Value)
+			if err != nil {
+				yield(DictChange[K, V]{}, err)
+				return
+			}
+			if !equal && !yield(DictChange[K, V]{
+				Kind: DictChanged,
+				Key:  kv.Key,
+				Old:  oldValue,
+				New:  kv.Value,
+			}, nil) {
+				return
+			}
+		}
+		for key, oldValue := range old {
+			if !yield(DictChange[K, V]{
+				Kind: DictRemoved,
+				Key:  key,
+				Old:  oldValue,
+			}, nil) {
+				return
+			}
+		}
+	}
+}
+
+// layerKeys returns the keys of the DictSet and DictRemove layers of a and b
+// above their closest common Dict, if any.
+func layerKeys[K interface {
+	comparable
+	core.Expression
+}, V core.Expression](a Dict[K, V], b Dict[K, V]) ([]K, bool) {
+	layersA := dictLayers(a)
+	layersB := dictLayers(b)
+	for i, layerA := range layersA {
+		for j, layerB := range layersB {
+			if !sameDict(layerA.dict, layerB.dict) {
+				continue
+			}
+			seen := make(map[K]bool)
+			var keys []K
+			for _, layer := range append(layersA[:i:i], layersB[:j]...) {
+				if !seen[layer.key] {
+					seen[layer.key] = true
+					keys = append(keys, layer.key)
+				}
+			}
+			return keys, true
+		}
+	}
+	return nil, false
+}
+
+type dictLayer[K interface {
+	comparable
+	core.Expression
+}, V core.Expression] struct {
+	dict Dict[K, V]
+	// key set or removed by dict over the next layer
+	key K
+}
+
+// dictLayers returns dict and the Dicts under its DictSet and DictRemove
+// layers, outermost first.
+func dictLayers[K interface {
+	comparable
+	core.Expression
+}, V core.Expression](dict Dict[K, V]) []dictLayer[K, V] {
+	var layers []dictLayer[K, V]
+	for {
+		switch d := dict.(type) {
+		case DictSet[K, V]:
+			layers = append(layers, dictLayer[K, V]{dict: d, key: d.Key})
+			dict = d.Dict
+			continue
+		case DictRemove[K, V]:
+			layers = append(layers, dictLayer[K, V]{dict: d, key: d.Key})
+			dict = d.Dict
+			continue
+		}
+		return append(layers, dictLayer[K, V]{dict: dict})
+	}
+}
+
+func diffKey[K interface {
+	comparable
+	core.Expression
+}, V core.Expression](ctx *core.Context, a Dict[K, V], b Dict[K, V], key K) (change DictChange[K, V], changed bool, err error) {
+	change.Key = key
+	inA, err := a.Exists(ctx, key)
+	if err != nil {
+		return change, false, err
+	}
+	if inA {
+		if change.Old, err = a.Get(ctx, key); err != nil {
+			return change, false, err
+		}
+	}
+	inB, err := b.Exists(ctx, key)
+	if err != nil {
+		return change, false, err
+	}
+	if inB {
+		if change.New, err = b.Get(ctx, key); err != nil {
+			return change, false, err
+		}
+	}
+
+	switch {
+	case inA && inB:
+		equal, err := equalValues(change.Old, change.New)
+		if err != nil || equal {
+			return change, false, err
+		}
+		change.Kind = DictChanged
+	case inA:
+		change.Kind = DictRemoved
+	case inB:
+		change.Kind = DictAdded
+	default:
+		return change, false, nil
+	}
+	return change, true, nil
+}
+
+// sameDict reports whether a and b are identical, or refer to the same
+// stored Dict.
+func sameDict[K interface {
+	comparable
+	core.Expression
+}, V core.Expression](a Dict[K, V], b Dict[K, V]) bool {
+	if refA, ok := a.(DictRef[K, V]); ok {
+		if refB, ok := b.(DictRef[K, V]); ok {
+			return refA.Ref.ID == refB.Ref.ID
+		}
+	}
+	return identical(a, b)
+}
+
+func equalValues[V core.Expression](a V, b V) (bool, error) {
+	if identical(a, b) {
+		return true, nil
+	}
+	return dshash.Equal(a, b)
+}
+
+// identical reports whether a and b are the same value, comparing maps,
+// slices and pointers by identity instead of content.
+func identical(a any, b any) bool {
+	return identicalValues(reflect.ValueOf(a), reflect.ValueOf(b))
+}
+
+func identicalValues(a reflect.Value, b reflect.Value) bool {
+	if !a.IsValid() || !b.IsValid() {
+		return !a.IsValid() && !b.IsValid()
+	}
+	if a.Type() != b.Type() {
+		return false
+	}
+	switch a.Kind() {
+	case reflect.Map, reflect.Pointer, reflect.Chan, reflect.UnsafePointer:
+		return a.UnsafePointer() == b.UnsafePointer()
+	case reflect.Slice:
+		return a.UnsafePointer() == b.UnsafePointer() && a.Len() == b.Len()
+	case reflect.Interface:
+		return identicalValues(a.Elem(), b.Elem())
+	case reflect.Struct:
+		for i := range a.NumField() {
+			if !identicalValues(a.Field(i), b.Field(i)) {
+				return false
+			}
+		}
+		return true
+	case reflect.Array:
+		for i := range a.Len() {
+			if !identicalValues(a.Index(i), b.Index(i)) {
+				return false
+			}
+		}
+		return true
+	case reflect.Func:
+		return false
+	}
+	return a.Equal(b)
+}
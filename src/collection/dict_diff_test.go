@@ -0,0 +1,86 @@
+package collection
+
+import (
+	"iter"
+	"slices"
+	"testing"
+
+	"github.com/ArborDB/arbordb/src/core"
+	"github.com/ArborDB/arbordb/src/scalar"
+)
+
+// countingDict counts the scans of a Dict
+type countingDict struct {
+	Map[scalar.String, scalar.Int]
+	scans *int
+}
+
+func (c countingDict) IterDict(ctx *core.Context) iter.Seq2[KV[scalar.String, scalar.Int], error] {
+	*c.scans++
+	return c.Map.IterDict(ctx)
+}
+
+func diffStrings(t *testing.T, a, b Dict[scalar.String, scalar.Int]) []string {
+	t.Helper()
+	var ret []string
+	for change, err := range DiffDict(nil, a, b) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		ret = append(ret, change.String())
+	}
+	slices.Sort(ret)
+	return ret
+}
+
+func TestDiffDict(t *testing.T) {
+	scans := 0
+	base := countingDict{
+		Map: Map[scalar.String, scalar.Int]{
+			"a": 1,
+			"b": 2,
+			"c": 3,
+		},
+		scans: &scans,
+	}
+
+	// layers over a common Dict
+	a := DictSet[scalar.String, scalar.Int]{Dict: base, Key: "d", Value: 4}
+	b := DictRemove[scalar.String, scalar.Int]{
+		Dict: DictSet[scalar.String, scalar.Int]{
+			Dict:  DictSet[scalar.String, scalar.Int]{Dict: base, Key: "a", Value: 10},
+			Key:   "b",
+			Value: 2,
+		},
+		Key: "c",
+	}
+	want := []string{"-c: 3", "-d: 4", "~a: 1 -> 10"}
+	if got := diffStrings(t, a, b); !slices.Equal(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	if got := diffStrings(t, base, b); !slices.Equal(got, []string{"-c: 3", "~a: 1 -> 10"}) {
+		t.Fatalf("got %q", got)
+	}
+	if got := diffStrings(t, b, base); !slices.Equal(got, []string{"+c: 3", "~a: 10 -> 1"}) {
+		t.Fatalf("got %q", got)
+	}
+	if got := diffStrings(t, base, base); len(got) != 0 {
+		t.Fatalf("got %q", got)
+	}
+	if scans != 0 {
+		t.Fatalf("layered diffs scanned %d times", scans)
+	}
+
+	// unrelated Dicts are scanned
+	other := Map[scalar.String, scalar.Int]{
+		"a": 1,
+		"b": 20,
+		"e": 5,
+	}
+	if got := diffStrings(t, a, other); !slices.Equal(got, []string{"+e: 5", "-c: 3", "-d: 4", "~b: 2 -> 20"}) {
+		t.Fatalf("got %q", got)
+	}
+	if scans != 1 {
+		t.Fatalf("got %d scans", scans)
+	}
+}
@@ -0,0 +1,106 @@
+package collection
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ArborDB/arbordb/src/core"
+	"github.com/ArborDB/arbordb/src/scalar"
+)
+
+// MergeConflict is a key changed differently by ours and theirs. The values
+// of a side are the zero value when the key is absent from it.
+type MergeConflict[K interface {
+	comparable
+	core.Expression
+}, V core.Expression] struct {
+	Key      K
+	Base     V
+	Ours     V
+	Theirs   V
+	InBase   bool
+	InOurs   bool
+	InTheirs bool
+}
+
+var _ core.Expression = MergeConflict[scalar.String, scalar.Int]{}
+
+func (c MergeConflict[K, V]) String() string {
+	return fmt.Sprintf("MergeConflict(%v, %v, %v, %v)", c.Key, c.Base, c.Ours, c.Theirs)
+}
+
+// Resolver returns the merged value of a conflicting key, or ok false to
+// leave the key out of the merged Dict.
+type Resolver[K interface {
+	comparable
+	core.Expression
+}, V core.Expression] func(ctx *core.Context, conflict MergeConflict[K, V]) (value V, ok bool, err error)
+
+var ErrMergeConflict = errors.New("merge conflict")
+
+// Merge3 applies the changes from base to theirs onto ours. Keys changed by
+// both sides to different values are resolved by resolve, or fail the merge
+// with ErrMergeConflict if resolve is nil.
+func Merge3[K interface {
+	comparable
+	core.Expression
+}, V core.Expression](ctx *core.Context, base Dict[K, V], ours Dict[K, V], theirs Dict[K, V], resolve Resolver[K, V]) (Map[K, V], error) {
+	var merged Map[K, V]
+	if err := (DictToMap[K, V]{}).Apply(ctx, ours, &merged); err != nil {
+		return nil, err
+	}
+
+	for theirChange, err := range DiffDict(ctx, base, theirs) {
+		if err != nil {
+			return nil, err
+		}
+		key := theirChange.Key
+		conflict := MergeConflict[K, V]{
+			Key:      key,
+			Base:     theirChange.Old,
+			Theirs:   theirChange.New,
+			InBase:   theirChange.Kind != DictAdded,
+			InTheirs: theirChange.Kind != DictRemoved,
+		}
+		conflict.Ours, conflict.InOurs = merged[key]
+
+		unchanged, err := sameEntry(conflict.Ours, conflict.InOurs, conflict.Base, conflict.InBase)
+		if err != nil {
+			return nil, err
+		}
+		if !unchanged {
+			// changed the same way by both
+			same, err := sameEntry(conflict.Ours, conflict.InOurs, conflict.Theirs, conflict.InTheirs)
+			if err != nil {
+				return nil, err
+			}
+			if same {
+				continue
+			}
+		}
+		value, ok := conflict.Theirs, conflict.InTheirs
+		if !unchanged {
+			if resolve == nil {
+				return nil, fmt.Errorf("%w: key %v", ErrMergeConflict, key)
+			}
+			value, ok, err = resolve(ctx, conflict)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if ok {
+			merged[key] = value
+		} else {
+			delete(merged, key)
+		}
+	}
+
+	return merged, nil
+}
+
+func sameEntry[V core.Expression](a V, inA bool, b V, inB bool) (bool, error) {
+	if !inA || !inB {
+		return inA == inB, nil
+	}
+	return equalValues(a, b)
+}
@@ -0,0 +1,72 @@
+package collection
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ArborDB/arbordb/src/core"
+	"github.com/ArborDB/arbordb/src/scalar"
+)
+
+func TestMerge3(t *testing.T) {
+	base := Map[scalar.String, scalar.Int]{
+		"a": 1,
+		"b": 2,
+		"c": 3,
+		"d": 4,
+	}
+	ours := DictRemove[scalar.String, scalar.Int]{
+		Dict: DictSet[scalar.String, scalar.Int]{
+			Dict:  DictSet[scalar.String, scalar.Int]{Dict: base, Key: "a", Value: 10},
+			Key:   "b",
+			Value: 20,
+		},
+		Key: "d",
+	}
+	theirs := DictSet[scalar.String, scalar.Int]{
+		Dict: DictSet[scalar.String, scalar.Int]{
+			Dict:  DictRemove[scalar.String, scalar.Int]{Dict: base, Key: "c"},
+			Key:   "a",
+			Value: 10,
+		},
+		Key:   "b",
+		Value: 200,
+	}
+	theirs = DictSet[scalar.String, scalar.Int]{Dict: theirs, Key: "e", Value: 5}
+
+	// conflicting without a resolver
+	if _, err := Merge3(nil, Dict[scalar.String, scalar.Int](base), ours, theirs, nil); !errors.Is(err, ErrMergeConflict) {
+		t.Fatalf("expected conflict, got %v", err)
+	}
+
+	var conflicts []string
+	merged, err := Merge3(nil, Dict[scalar.String, scalar.Int](base), ours, theirs, func(ctx *core.Context, conflict MergeConflict[scalar.String, scalar.Int]) (scalar.Int, bool, error) {
+		conflicts = append(conflicts, conflict.String())
+		return conflict.Ours + conflict.Theirs, true, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(conflicts) != 1 || conflicts[0] != "MergeConflict(b, 2, 20, 200)" {
+		t.Fatalf("got conflicts %q", conflicts)
+	}
+	want := Map[scalar.String, scalar.Int]{
+		"a": 10,
+		"b": 220,
+		"e": 5,
+	}
+	if got := diffStrings(t, merged, want); len(got) != 0 {
+		t.Fatalf("got %v, diff %q", merged, got)
+	}
+
+	// removing a conflicting key
+	merged, err = Merge3(nil, Dict[scalar.String, scalar.Int](base), ours, theirs, func(ctx *core.Context, conflict MergeConflict[scalar.String, scalar.Int]) (scalar.Int, bool, error) {
+		return 0, false, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := merged["b"]; ok {
+		t.Fatal("b not removed")
+	}
+}
@@ -0,0 +1,76 @@
+package collection
+
+import (
+	"fmt"
+	"iter"
+
+	"github.com/ArborDB/arbordb/src/core"
+	"github.com/ArborDB/arbordb/src/scalar"
+)
+
+// DictRef is a Dict stored in a PhysicalStorage, loaded on first access.
+type DictRef[K interface {
+	comparable
+	core.Expression
+}, V core.Expression] struct {
+	Ref core.Ref[Dict[K, V]]
+}
+
+// StoreDict stores dict in ctx.PhysicalStorage and returns a DictRef to it.
+func StoreDict[K interface {
+	comparable
+	core.Expression
+}, V core.Expression](ctx *core.Context, dict Dict[K, V]) (DictRef[K, V], error) {
+	ref, err := core.StoreRef(ctx, dict)
+	if err != nil {
+		return DictRef[K, V]{}, err
+	}
+	return DictRef[K, V]{Ref: ref}, nil
+}
+
+var _ core.Expression = DictRef[scalar.String, scalar.Int]{}
+
+func (dr DictRef[K, V]) String() string {
+	return fmt.Sprintf("DictRef(%v)", dr.Ref.ID)
+}
+
+var _ Dict[scalar.String, scalar.Int] = DictRef[scalar.String, scalar.Int]{}
+
+func (dr DictRef[K, V]) Get(ctx *core.Context, key K) (value V, err error) {
+	dict, err := dr.Ref.Get(ctx)
+	if err != nil {
+		return value, err
+	}
+	return dict.Get(ctx, key)
+}
+
+func (dr DictRef[K, V]) Exists(ctx *core.Context, key K) (bool, error) {
+	dict, err := dr.Ref.Get(ctx)
+	if err != nil {
+		return false, err
+	}
+	return dict.Exists(ctx, key)
+}
+
+func (dr DictRef[K, V]) Size(ctx *core.Context) (int, error) {
+	dict, err := dr.Ref.Get(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return dict.Size(ctx)
+}
+
+func (dr DictRef[K, V]) IterDict(ctx *core.Context) iter.Seq2[KV[K, V], error] {
+	return func(yield func(KV[K, V], error) bool) {
+		dict, err := dr.Ref.Get(ctx)
+		if err != nil {
+			yield(KV[K, V]{}, err)
+			return
+		}
+		for kv, err := range dict.IterDict(ctx) {
+			if !yield(kv, err) || err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package collection
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/ArborDB/arbordb/src/core"
+	"github.com/ArborDB/arbordb/src/scalar"
+	"github.com/ArborDB/arbordb/src/storage"
+)
+
+func TestDictRef(t *testing.T) {
+	ctx := &core.Context{PhysicalStorage: storage.NewMemory()}
+	scans := 0
+	base := countingDict{
+		Map:   Map[scalar.String, scalar.Int]{"a": 1, "b": 2},
+		scans: &scans,
+	}
+	ref, err := StoreDict[scalar.String, scalar.Int](ctx, base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, err := ref.Get(ctx, "b"); err != nil || v != 2 {
+		t.Fatalf("got %v, %v", v, err)
+	}
+
+	// refs to the same Dict are compared by identifier
+	loaded := DictRef[scalar.String, scalar.Int]{Ref: core.NewRef[Dict[scalar.String, scalar.Int]](ref.Ref.ID)}
+	a := DictSet[scalar.String, scalar.Int]{Dict: ref, Key: "c", Value: 3}
+	var got []string
+	for change, err := range DiffDict(ctx, Dict[scalar.String, scalar.Int](a), loaded) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, change.String())
+	}
+	if !slices.Equal(got, []string{"-c: 3"}) {
+		t.Fatalf("got %q", got)
+	}
+	if scans != 0 {
+		t.Fatalf("scanned %d times", scans)
+	}
+}
@@ -0,0 +1,21 @@
+package collection
+
+import (
+	"encoding/gob"
+
+	"github.com/ArborDB/arbordb/src/core"
+	"github.com/ArborDB/arbordb/src/scalar"
+)
+
+// The collections of expressions by String are registered with gob, to be
+// encoded as expressions by the storage wrappers and by replication.
+func init() {
+	for _, value := range []core.Expression{
+		Map[scalar.String, core.Expression]{},
+		DictSet[scalar.String, core.Expression]{},
+		DictRemove[scalar.String, core.Expression]{},
+		DictRef[scalar.String, core.Expression]{},
+	} {
+		gob.Register(value)
+	}
+}
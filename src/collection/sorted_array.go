@@ -0,0 +1,53 @@
+package collection
+
+import (
+	"fmt"
+	"iter"
+	"slices"
+
+	"github.com/ArborDB/arbordb/src/core"
+	"github.com/ArborDB/arbordb/src/scalar"
+)
+
+// SortedArray is an Array whose elements are in ascending order.
+type SortedArray[T core.Ordered[T]] []T
+
+var _ core.Expression = SortedArray[scalar.Int]{}
+
+func (a SortedArray[T]) String() string {
+	return fmt.Sprintf(`SortedArray(%v)`, []T(a))
+}
+
+var _ SortedList[scalar.Int] = SortedArray[scalar.Int]{}
+
+func (a SortedArray[T]) IsEmpty(ctx *core.Context) (bool, error) {
+	return len(a) == 0, nil
+}
+
+func (a SortedArray[T]) Iter(ctx *core.Context) iter.Seq2[T, error] {
+	return Array[T](a).Iter(ctx)
+}
+
+func (a SortedArray[T]) Length(ctx *core.Context) (int, error) {
+	return len(a), nil
+}
+
+func (a SortedArray[T]) At(ctx *core.Context, index int) (T, error) {
+	return a[index], nil
+}
+
+// BinarySearch returns the position of target, or -(insertion point)-1 if
+// it is absent.
+func (a SortedArray[T]) BinarySearch(ctx *core.Context, target T) (int, error) {
+	pos, found := slices.BinarySearchFunc(a, target, T.Compare)
+	if !found {
+		return -pos - 1, nil
+	}
+	return pos, nil
+}
+
+var _ core.CanonicalList = SortedArray[scalar.Int]{}
+
+func (a SortedArray[T]) IterCanonical(ctx *core.Context) iter.Seq2[core.Expression, error] {
+	return Array[T](a).IterCanonical(ctx)
+}
@@ -12,3 +12,9 @@ type ErrCanceled struct{}
 func (e ErrCanceled) Error() string {
 	return "canceled"
 }
+
+type ErrNotFound struct{}
+
+func (e ErrNotFound) Error() string {
+	return "not found"
+}
@@ -2,6 +2,7 @@ package core
 
 import (
 	"crypto/sha256"
+	"encoding/binary"
 	"encoding/hex"
 	"fmt"
 	"hash"
@@ -104,7 +105,9 @@ func (g ToCanonicalID) EstimateCost(ctx *Context, from Expression) (Cost, error)
 
 func (g ToCanonicalID) Apply(ctx *Context, from Expression, to *Identifier) error {
 	state := sha256.New()
-	visited := make(map[unsafe.Pointer]struct{})
+	// depth of each pointer on the current path, for cycle back-references
+	visited := make(map[unsafe.Pointer]int)
+	depth := 0
 
 	var hashRecursive func(h hash.Hash, expr Expression) error
 	hashRecursive = func(h hash.Hash, expr Expression) error {
@@ -116,12 +119,18 @@ func (g ToCanonicalID) Apply(ctx *Context, from Expression, to *Identifier) erro
 		val := reflect.ValueOf(expr)
 		if val.Kind() == reflect.Pointer && !val.IsNil() {
 			ptr := val.UnsafePointer()
-			if _, ok := visited[ptr]; ok {
+			if target, ok := visited[ptr]; ok {
+				// cycle detected, encode the distance to the target ancestor
 				h.Write([]byte{byte(CanonicalTagCycle)})
-				return nil // cycle detected
+				binary.Write(h, binary.LittleEndian, int64(depth-target))
+				return nil
 			}
-			visited[ptr] = struct{}{}
-			defer delete(visited, ptr)
+			visited[ptr] = depth
+			depth++
+			defer func() {
+				depth--
+				delete(visited, ptr)
+			}()
 		}
 
 		switch e := expr.(type) {
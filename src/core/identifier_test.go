@@ -102,3 +102,26 @@ func TestCanonicalDAG(t *testing.T) {
 		t.Fatalf("DAG and Tree should have same Canonical ID: %v vs %v", idDAG, idTree)
 	}
 }
+
+func TestCanonicalCycleTarget(t *testing.T) {
+	// root -> [child -> [root]]
+	root1 := &dagNode{Name: "root"}
+	root1.Children = []Expression{&dagNode{Name: "child", Children: []Expression{root1}}}
+
+	// root -> [child -> [child]]
+	child2 := &dagNode{Name: "child"}
+	child2.Children = []Expression{child2}
+	root2 := &dagNode{Name: "root", Children: []Expression{child2}}
+
+	var transformer ToCanonicalID
+	var id1, id2 Identifier
+	if err := transformer.Apply(nil, root1, &id1); err != nil {
+		t.Fatal(err)
+	}
+	if err := transformer.Apply(nil, root2, &id2); err != nil {
+		t.Fatal(err)
+	}
+	if id1 == id2 {
+		t.Fatalf("cycles to different ancestors should have different Canonical IDs: %v", id1)
+	}
+}
@@ -1,6 +1,15 @@
 package core
 
+import "iter"
+
 type PhysicalStorage interface {
 	Set(ctx *Context, expr Expression) (id Identifier, err error)
+	// Get returns an error that is ErrNotFound if id is not stored.
 	Get(ctx *Context, id Identifier, target any) (err error)
+	Has(ctx *Context, id Identifier) (ok bool, err error)
+	// Delete removes the expression of id, if stored.
+	Delete(ctx *Context, id Identifier) (err error)
+	// Iterate yields the identifiers of the stored expressions, in no
+	// particular order.
+	Iterate(ctx *Context) iter.Seq2[Identifier, error]
 }
@@ -0,0 +1,117 @@
+package core
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"sync"
+)
+
+// Ref is an expression stored in a PhysicalStorage, referred to by its
+// Identifier. It is loaded through ctx.PhysicalStorage on first access and
+// cached by the copies of a Ref created by NewRef, StoreRef or gob decoding.
+// A zero Ref, or one decoded otherwise, loads on every access. It hashes as
+// its Identifier, so expressions holding Refs to stored children do not
+// depend on the contents of the children.
+type Ref[T Expression] struct {
+	ID    Identifier
+	cache *refCache[T] `dshash:"-"`
+}
+
+type refCache[T Expression] struct {
+	mu     sync.Mutex
+	loaded bool
+	value  T
+}
+
+func NewRef[T Expression](id Identifier) Ref[T] {
+	return Ref[T]{
+		ID:    id,
+		cache: new(refCache[T]),
+	}
+}
+
+// StoreRef stores value in ctx.PhysicalStorage and returns a Ref to it,
+// with value cached.
+func StoreRef[T Expression](ctx *Context, value T) (Ref[T], error) {
+	if ctx == nil || ctx.PhysicalStorage == nil {
+		return Ref[T]{}, fmt.Errorf("no physical storage to store %T", value)
+	}
+	id, err := ctx.PhysicalStorage.Set(ctx, value)
+	if err != nil {
+		return Ref[T]{}, err
+	}
+	return Ref[T]{
+		ID: id,
+		cache: &refCache[T]{
+			loaded: true,
+			value:  value,
+		},
+	}, nil
+}
+
+var _ Expression = Ref[Identifier]{}
+
+func (r Ref[T]) String() string {
+	return "Ref(" + r.ID.String() + ")"
+}
+
+// Reference is implemented by the values referring to another stored
+// expression, like Ref. Walks over stored expressions follow references only,
+// an Identifier alone is a plain value.
+type Reference interface {
+	Referenced() Identifier
+}
+
+var _ Reference = Ref[Identifier]{}
+
+// Referenced returns the identifier of the referenced expression.
+func (r Ref[T]) Referenced() Identifier {
+	return r.ID
+}
+
+// Get returns the referenced expression, loading it if not cached.
+func (r Ref[T]) Get(ctx *Context) (T, error) {
+	if r.cache == nil {
+		// decoded or zero Ref
+		return r.load(ctx)
+	}
+	r.cache.mu.Lock()
+	defer r.cache.mu.Unlock()
+	if r.cache.loaded {
+		return r.cache.value, nil
+	}
+	value, err := r.load(ctx)
+	if err != nil {
+		return value, err
+	}
+	r.cache.value = value
+	r.cache.loaded = true
+	return value, nil
+}
+
+// GobEncode encodes the identifier of the Ref.
+func (r Ref[T]) GobEncode() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(r.ID); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// GobDecode decodes a Ref with an empty cache, so that copies of a decoded
+// Ref share the loaded expression too.
+func (r *Ref[T]) GobDecode(data []byte) error {
+	r.cache = new(refCache[T])
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(&r.ID)
+}
+
+func (r Ref[T]) load(ctx *Context) (value T, err error) {
+	if ctx == nil || ctx.PhysicalStorage == nil {
+		return value, fmt.Errorf("no physical storage to load %v", r.ID)
+	}
+	if err := ctx.PhysicalStorage.Get(ctx, r.ID, &value); err != nil {
+		return value, fmt.Errorf("load %v: %w", r.ID, err)
+	}
+	return value, nil
+}
@@ -0,0 +1,126 @@
+package core
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"iter"
+	"reflect"
+	"testing"
+)
+
+type text string
+
+func (t text) String() string {
+	return string(t)
+}
+
+// countingStorage stores expressions by their string, counting loads
+type countingStorage struct {
+	items map[Identifier]Expression
+	gets  int
+}
+
+func (c *countingStorage) Set(ctx *Context, expr Expression) (Identifier, error) {
+	id := Identifier{Kind: "test", Key: expr.String()}
+	c.items[id] = expr
+	return id, nil
+}
+
+func (c *countingStorage) Get(ctx *Context, id Identifier, target any) error {
+	c.gets++
+	expr, ok := c.items[id]
+	if !ok {
+		return fmt.Errorf("%v: %w", id, Err[ErrNotFound]())
+	}
+	reflect.ValueOf(target).Elem().Set(reflect.ValueOf(expr))
+	return nil
+}
+
+func (c *countingStorage) Has(ctx *Context, id Identifier) (bool, error) {
+	_, ok := c.items[id]
+	return ok, nil
+}
+
+func (c *countingStorage) Delete(ctx *Context, id Identifier) error {
+	delete(c.items, id)
+	return nil
+}
+
+func (c *countingStorage) Iterate(ctx *Context) iter.Seq2[Identifier, error] {
+	return func(yield func(Identifier, error) bool) {
+		for id := range c.items {
+			if !yield(id, nil) {
+				return
+			}
+		}
+	}
+}
+
+func TestRef(t *testing.T) {
+	storage := &countingStorage{items: make(map[Identifier]Expression)}
+	ctx := &Context{PhysicalStorage: storage}
+
+	stored, err := StoreRef[Expression](ctx, text("a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, err := stored.Get(ctx); err != nil || v != text("a") {
+		t.Fatalf("got %v, %v", v, err)
+	}
+	if storage.gets != 0 {
+		t.Fatal("stored value loaded")
+	}
+
+	// loaded once by all copies
+	ref := NewRef[text](stored.ID)
+	for _, r := range []Ref[text]{ref, ref} {
+		if v, err := r.Get(ctx); err != nil || v != "a" {
+			t.Fatalf("got %v, %v", v, err)
+		}
+	}
+	if storage.gets != 1 {
+		t.Fatalf("loaded %d times", storage.gets)
+	}
+
+	// hashed as the identifier
+	var cached, decoded Identifier
+	if err := (ToPhysicalID{}).Apply(ctx, ref, &cached); err != nil {
+		t.Fatal(err)
+	}
+	if err := (ToPhysicalID{}).Apply(ctx, Ref[text]{ID: stored.ID}, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if cached != decoded {
+		t.Fatal("cache changed the hash")
+	}
+
+	// gob decoded Refs cache too
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(ref); err != nil {
+		t.Fatal(err)
+	}
+	var gobRef Ref[text]
+	if err := gob.NewDecoder(&buf).Decode(&gobRef); err != nil {
+		t.Fatal(err)
+	}
+	if gobRef.ID != ref.ID {
+		t.Fatalf("got %v", gobRef.ID)
+	}
+	storage.gets = 0
+	for range 2 {
+		if v, err := gobRef.Get(ctx); err != nil || v != "a" {
+			t.Fatalf("got %v, %v", v, err)
+		}
+	}
+	if storage.gets != 1 {
+		t.Fatalf("loaded %d times", storage.gets)
+	}
+
+	if _, err := NewRef[text](Identifier{Kind: "test", Key: "missing"}).Get(ctx); err == nil {
+		t.Fatal("expected error")
+	}
+	if _, err := ref.Get(nil); err != nil {
+		t.Fatal("cached value not returned", err)
+	}
+}
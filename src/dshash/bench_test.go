@@ -41,4 +41,35 @@ func BenchmarkMap(b *testing.B) {
 			_ = state.Sum(nil)
 		}
 	})
+
+	b.Run("LargeMap_ComplexKeys_Parallel", func(b *testing.B) {
+		for b.Loop() {
+			state := sha256.New()
+			_ = HashParallel(state, largeMap)
+			_ = state.Sum(nil)
+		}
+	})
+}
+
+func BenchmarkList(b *testing.B) {
+	list := make([]string, 100000)
+	for i := range list {
+		list[i] = fmt.Sprintf("item-%d", i)
+	}
+
+	b.Run("Sequential", func(b *testing.B) {
+		for b.Loop() {
+			state := sha256.New()
+			_ = Hash(state, list)
+			_ = state.Sum(nil)
+		}
+	})
+
+	b.Run("Parallel", func(b *testing.B) {
+		for b.Loop() {
+			state := sha256.New()
+			_ = HashParallel(state, list)
+			_ = state.Sum(nil)
+		}
+	})
 }
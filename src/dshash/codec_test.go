@@ -0,0 +1,223 @@
+package dshash
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"math/big"
+	"net/netip"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type codecValue struct {
+	Bool     bool
+	Int      int32
+	Uint     uint8
+	Float    float64
+	Complex  complex64
+	String   string
+	Bytes    []byte
+	Array    [3]byte
+	List     []int
+	Map      map[string][]int
+	Ptr      *codecValue
+	Any      any
+	Time     time.Time
+	Duration time.Duration
+	BigInt   *big.Int
+	BigRat   big.Rat
+	Addr     netip.Addr
+	Prefix   netip.Prefix
+	hidden   string
+}
+
+func TestEncoderMatchesHash(t *testing.T) {
+	value := map[string]any{
+		"A": 42,
+		"B": []any{1, "foo", nil},
+		"C": time.Second,
+	}
+	buf := new(bytes.Buffer)
+	if err := NewEncoder(buf).Encode(value); err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(buf.Bytes())
+	if hex.EncodeToString(sum[:]) != sumOf(t, value) {
+		t.Fatal("encoded stream does not match hashed stream")
+	}
+}
+
+func TestCodecRoundTrip(t *testing.T) {
+	value := codecValue{
+		Bool:     true,
+		Int:      -42,
+		Uint:     42,
+		Float:    0.5,
+		Complex:  1 + 2i,
+		String:   "foo",
+		Bytes:    []byte("bar"),
+		Array:    [3]byte{1, 2, 3},
+		List:     []int{1, 2, 3},
+		Map:      map[string][]int{"a": {1}, "b": nil},
+		Ptr:      &codecValue{String: "child"},
+		Any:      []any{int64(1), "foo"},
+		Time:     time.Date(2024, 3, 1, 12, 0, 0, 42, time.UTC),
+		Duration: time.Minute,
+		BigInt:   big.NewInt(-1234567890123),
+		BigRat:   *big.NewRat(3, 4),
+		Addr:     netip.MustParseAddr("10.0.0.1"),
+		Prefix:   netip.MustParsePrefix("fe80::/10"),
+		hidden:   "hidden",
+	}
+
+	data, err := Marshal(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded codecValue
+	if err := Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if sumOf(t, decoded) != sumOf(t, value) {
+		t.Fatalf("round trip mismatch: %+v", decoded)
+	}
+	if decoded.hidden != "hidden" || decoded.Ptr.String != "child" {
+		t.Fatalf("got %+v", decoded)
+	}
+
+	// a stream can hold several values
+	buf := new(bytes.Buffer)
+	encoder := NewEncoder(buf)
+	for i := range 3 {
+		if err := encoder.Encode(i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	decoder := NewDecoder(buf)
+	for i := range 3 {
+		var n int
+		if err := decoder.Decode(&n); err != nil {
+			t.Fatal(err)
+		}
+		if n != i {
+			t.Fatalf("expected %d, got %d", i, n)
+		}
+	}
+}
+
+func TestDecodeGeneric(t *testing.T) {
+	data, err := Marshal(map[string]any{
+		"A": 42,
+		"B": []string{"foo"},
+		"C": big.NewFloat(1.5),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded any
+	if err := Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	m, ok := decoded.(map[any]any)
+	if !ok {
+		t.Fatalf("got %T", decoded)
+	}
+	if m["A"] != int64(42) {
+		t.Fatalf("got %#v", m["A"])
+	}
+	if !reflect.DeepEqual(m["B"], []any{"foo"}) {
+		t.Fatalf("got %#v", m["B"])
+	}
+	if f := m["C"].(*big.Float); f.Cmp(big.NewFloat(1.5)) != 0 {
+		t.Fatalf("got %v", f)
+	}
+}
+
+func TestDecodeUnknownField(t *testing.T) {
+	data, err := Marshal(struct {
+		A int
+		B map[string][]int
+		C string
+	}{
+		A: 1,
+		B: map[string][]int{"foo": {1, 2}},
+		C: "bar",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded struct {
+		A int
+		C string
+	}
+	if err := Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.A != 1 || decoded.C != "bar" {
+		t.Fatalf("got %+v", decoded)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	data, err := Marshal("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var n int
+	if err := Unmarshal(data, &n); err == nil {
+		t.Fatal("expected kind mismatch")
+	}
+	if err := Unmarshal(data[:len(data)-1], new(string)); err == nil {
+		t.Fatal("expected error for truncated stream")
+	}
+
+	data, err = Marshal(300)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Unmarshal(data, new(int8)); err == nil {
+		t.Fatal("expected overflow")
+	}
+
+	// a pointer to itself carries no value to reconstruct
+	type P *P
+	var p P
+	p = &p
+	data, err = Marshal(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Unmarshal(data, new(P)); err == nil {
+		t.Fatal("expected error for cycle")
+	}
+}
+
+type codecNode struct {
+	Label    string
+	Children []*codecNode
+}
+
+func TestDecodeCycle(t *testing.T) {
+	root := &codecNode{Label: "root"}
+	child := &codecNode{Label: "child"}
+	root.Children = []*codecNode{child, child}
+	child.Children = []*codecNode{root, child}
+
+	data, err := Marshal(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded *codecNode
+	if err := Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if sumOf(t, decoded) != sumOf(t, root) {
+		t.Fatal("round trip mismatch")
+	}
+	decodedChild := decoded.Children[0]
+	if decodedChild.Children[0] != decoded || decodedChild.Children[1] != decodedChild {
+		t.Fatal("cycles not reconstructed")
+	}
+}
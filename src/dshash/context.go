@@ -1,11 +1,22 @@
 package dshash
 
 import (
-	"hash"
+	"io"
 	"unsafe"
 )
 
 type Context struct {
-	state   hash.Hash
-	visited map[unsafe.Pointer]struct{}
+	state io.Writer
+	// depth of each pointer on the current path, for cycle back-references
+	visited map[unsafe.Pointer]int
+	depth   int
+	// tree hash large lists and maps, see HashParallel
+	parallel bool
+}
+
+func newContext(w io.Writer) *Context {
+	return &Context{
+		state:   w,
+		visited: make(map[unsafe.Pointer]int),
+	}
 }
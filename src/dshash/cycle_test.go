@@ -0,0 +1,117 @@
+package dshash
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"strings"
+	"testing"
+)
+
+type graphNode struct {
+	Label string
+	Left  *graphNode
+	Right *graphNode
+}
+
+func TestHashCycleTarget(t *testing.T) {
+	// root -> child -> root
+	root1 := &graphNode{Label: "a"}
+	child1 := &graphNode{Label: "b", Left: root1}
+	root1.Left = child1
+
+	// root -> child -> child
+	root2 := &graphNode{Label: "a"}
+	child2 := &graphNode{Label: "b"}
+	child2.Left = child2
+	root2.Left = child2
+
+	if sumOf(t, root1) == sumOf(t, root2) {
+		t.Fatal("cycles to different ancestors should hash differently")
+	}
+}
+
+// unrolled renders the graph reachable from node as a tree, with cycles
+// rendered as the distance to the ancestor they point to.
+func unrolled(node *graphNode, path []*graphNode) string {
+	if node == nil {
+		return "nil"
+	}
+	for i, ancestor := range path {
+		if ancestor == node {
+			return fmt.Sprintf("^%d", len(path)-i)
+		}
+	}
+	path = append(path, node)
+	return fmt.Sprintf("(%s %s %s)", node.Label, unrolled(node.Left, path), unrolled(node.Right, path))
+}
+
+func randomGraph(rng *rand.Rand) *graphNode {
+	nodes := make([]*graphNode, 1+rng.IntN(3))
+	for i := range nodes {
+		nodes[i] = &graphNode{
+			Label: strings.Repeat("a", 1+rng.IntN(2)),
+		}
+	}
+	pick := func() *graphNode {
+		i := rng.IntN(len(nodes) + 1)
+		if i == len(nodes) {
+			return nil
+		}
+		return nodes[i]
+	}
+	for _, node := range nodes {
+		node.Left = pick()
+		node.Right = pick()
+	}
+	return nodes[0]
+}
+
+func TestHashGraphProperty(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	type Sample struct {
+		sum      string
+		unrolled string
+	}
+	var samples []Sample
+	for range 300 {
+		graph := randomGraph(rng)
+		samples = append(samples, Sample{
+			sum:      sumOf(t, graph),
+			unrolled: unrolled(graph, nil),
+		})
+	}
+
+	equalPairs := 0
+	for i, a := range samples {
+		for _, b := range samples[i+1:] {
+			sameHash := a.sum == b.sum
+			sameShape := a.unrolled == b.unrolled
+			if sameHash != sameShape {
+				t.Fatalf("hash equality %v but shape equality %v:\n%s\n%s", sameHash, sameShape, a.unrolled, b.unrolled)
+			}
+			if sameShape {
+				equalPairs++
+			}
+		}
+	}
+	if equalPairs == 0 {
+		t.Fatal("no isomorphic pairs generated")
+	}
+}
+
+func TestHashSharedAcyclic(t *testing.T) {
+	// a shared but acyclic pointer hashes like two copies
+	leaf := &graphNode{Label: "leaf"}
+	shared := &graphNode{Label: "root", Left: leaf, Right: leaf}
+	copied := &graphNode{Label: "root", Left: &graphNode{Label: "leaf"}, Right: &graphNode{Label: "leaf"}}
+	if sumOf(t, shared) != sumOf(t, copied) {
+		t.Fatal("shared and copied pointers should hash the same")
+	}
+
+	// also when the shared pointer is reached through different depths
+	deep := &graphNode{Label: "root", Left: &graphNode{Label: "mid", Left: leaf}, Right: leaf}
+	deepCopy := &graphNode{Label: "root", Left: &graphNode{Label: "mid", Left: &graphNode{Label: "leaf"}}, Right: &graphNode{Label: "leaf"}}
+	if sumOf(t, deep) != sumOf(t, deepCopy) {
+		t.Fatal("shared and copied pointers should hash the same")
+	}
+}
@@ -0,0 +1,564 @@
+package dshash
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/big"
+	"net/netip"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+	"unsafe"
+)
+
+// Decoder reads values from a canonical byte stream written by Encoder.
+//
+// The stream carries no type information, so values are reconstructed
+// according to the target type. Values decoded into interface types take
+// their natural Go representation: int64, uint64, float64, complex128, string,
+// []any, map[any]any, time.Time, time.Duration, *big.Int, *big.Float, *big.Rat
+// and the netip types.
+type Decoder struct {
+	r *bufio.Reader
+	// pointers on the current path, targets of cycle back-references
+	ancestors []reflect.Value
+}
+
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{
+		r: bufio.NewReader(r),
+	}
+}
+
+func (d *Decoder) Decode(target any) error {
+	ptr := reflect.ValueOf(target)
+	if ptr.Kind() != reflect.Pointer || ptr.IsNil() {
+		return fmt.Errorf("target must be a non-nil pointer")
+	}
+	kind, err := d.readKind()
+	if err != nil {
+		return err
+	}
+	return d.decodeValue(kind, ptr.Elem())
+}
+
+func Unmarshal(data []byte, target any) error {
+	return NewDecoder(bytes.NewReader(data)).Decode(target)
+}
+
+func (d *Decoder) readKind() (kind Kind, err error) {
+	_, err = io.ReadFull(d.r, kind[:])
+	return
+}
+
+func (d *Decoder) readInt() (n int64, err error) {
+	err = binary.Read(d.r, binary.LittleEndian, &n)
+	return
+}
+
+func (d *Decoder) readBytes() ([]byte, error) {
+	n, err := d.readInt()
+	if err != nil {
+		return nil, err
+	}
+	if n < 0 {
+		return nil, fmt.Errorf("invalid length: %d", n)
+	}
+	// copy instead of allocating n bytes upfront, the length is not trusted
+	buf := new(bytes.Buffer)
+	if _, err := io.CopyN(buf, d.r, n); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (d *Decoder) readBigInt() (*big.Int, error) {
+	var sign int8
+	if err := binary.Read(d.r, binary.LittleEndian, &sign); err != nil {
+		return nil, err
+	}
+	bs, err := d.readBytes()
+	if err != nil {
+		return nil, err
+	}
+	n := new(big.Int).SetBytes(bs)
+	if sign < 0 {
+		n.Neg(n)
+	}
+	return n, nil
+}
+
+func (d *Decoder) decodeValue(kind Kind, value reflect.Value) error {
+	if !value.CanSet() {
+		// unexported struct field of an addressable struct
+		value = reflect.NewAt(value.Type(), unsafe.Pointer(value.UnsafeAddr())).Elem()
+	}
+	t := value.Type()
+
+	if kind == KindCycle {
+		return d.decodeCycle(value)
+	}
+
+	if t.Kind() == reflect.Pointer {
+		if kind == KindNil {
+			value.SetZero()
+			return nil
+		}
+		if value.IsNil() {
+			value.Set(reflect.New(t.Elem()))
+		}
+		d.ancestors = append(d.ancestors, value)
+		err := d.decodeValue(kind, value.Elem())
+		d.ancestors = d.ancestors[:len(d.ancestors)-1]
+		return err
+	}
+
+	if t.Kind() == reflect.Interface {
+		if kind == KindNil {
+			value.SetZero()
+			return nil
+		}
+		generic, err := genericType(kind)
+		if err != nil {
+			return err
+		}
+		elem := reflect.New(generic).Elem()
+		if err := d.decodeValue(kind, elem); err != nil {
+			return err
+		}
+		if !generic.AssignableTo(t) {
+			return fmt.Errorf("cannot assign %v to %v", generic, t)
+		}
+		value.Set(elem)
+		return nil
+	}
+
+	if kind == KindNil {
+		// unsupported values and nil pointers are encoded as nil
+		value.SetZero()
+		return nil
+	}
+	if special, ok := specialType(t); ok {
+		if kind != specialKinds[special] {
+			return mismatch(kind, t)
+		}
+		return d.decodeSpecial(kind, value)
+	}
+
+	switch t.Kind() {
+
+	case reflect.Bool:
+		if kind != KindBool {
+			return mismatch(kind, t)
+		}
+		b, err := d.r.ReadByte()
+		if err != nil {
+			return err
+		}
+		value.SetBool(b != 0)
+		return nil
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if kind != KindInt {
+			return mismatch(kind, t)
+		}
+		n, err := d.readInt()
+		if err != nil {
+			return err
+		}
+		if value.OverflowInt(n) {
+			return fmt.Errorf("value %d overflows %v", n, t)
+		}
+		value.SetInt(n)
+		return nil
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if kind != KindUint {
+			return mismatch(kind, t)
+		}
+		var n uint64
+		if err := binary.Read(d.r, binary.LittleEndian, &n); err != nil {
+			return err
+		}
+		if value.OverflowUint(n) {
+			return fmt.Errorf("value %d overflows %v", n, t)
+		}
+		value.SetUint(n)
+		return nil
+
+	case reflect.Float32, reflect.Float64:
+		if kind != KindFloat {
+			return mismatch(kind, t)
+		}
+		var f float64
+		if err := binary.Read(d.r, binary.LittleEndian, &f); err != nil {
+			return err
+		}
+		value.SetFloat(f)
+		return nil
+
+	case reflect.Complex64, reflect.Complex128:
+		if kind != KindComplex {
+			return mismatch(kind, t)
+		}
+		var parts [2]float64
+		if err := binary.Read(d.r, binary.LittleEndian, &parts); err != nil {
+			return err
+		}
+		value.SetComplex(complex(parts[0], parts[1]))
+		return nil
+
+	case reflect.String:
+		if kind != KindString {
+			return mismatch(kind, t)
+		}
+		bs, err := d.readBytes()
+		if err != nil {
+			return err
+		}
+		value.SetString(string(bs))
+		return nil
+
+	case reflect.Slice:
+		if t.Elem() == byteType {
+			if kind != KindString {
+				return mismatch(kind, t)
+			}
+			bs, err := d.readBytes()
+			if err != nil {
+				return err
+			}
+			value.SetBytes(bs)
+			return nil
+		}
+		if kind != KindList {
+			return mismatch(kind, t)
+		}
+		slice := reflect.MakeSlice(t, 0, 0)
+		for {
+			kind, err := d.readKind()
+			if err != nil {
+				return err
+			}
+			if kind == KindListEnd {
+				break
+			}
+			slice = reflect.Append(slice, reflect.Zero(t.Elem()))
+			if err := d.decodeValue(kind, slice.Index(slice.Len()-1)); err != nil {
+				return err
+			}
+		}
+		value.Set(slice)
+		return nil
+
+	case reflect.Array:
+		value.SetZero()
+		if t.Elem() == byteType {
+			if kind != KindString {
+				return mismatch(kind, t)
+			}
+			bs, err := d.readBytes()
+			if err != nil {
+				return err
+			}
+			if len(bs) != t.Len() {
+				return fmt.Errorf("cannot decode %d bytes into %v", len(bs), t)
+			}
+			reflect.Copy(value, reflect.ValueOf(bs))
+			return nil
+		}
+		if kind != KindList {
+			return mismatch(kind, t)
+		}
+		for i := 0; ; i++ {
+			kind, err := d.readKind()
+			if err != nil {
+				return err
+			}
+			if kind == KindListEnd {
+				break
+			}
+			if i >= t.Len() {
+				return fmt.Errorf("too many elements for %v", t)
+			}
+			if err := d.decodeValue(kind, value.Index(i)); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case reflect.Map:
+		if kind != KindMap {
+			return mismatch(kind, t)
+		}
+		m := reflect.MakeMap(t)
+		for {
+			kind, err := d.readKind()
+			if err != nil {
+				return err
+			}
+			if kind == KindMapEnd {
+				break
+			}
+			key := reflect.New(t.Key()).Elem()
+			if err := d.decodeValue(kind, key); err != nil {
+				return err
+			}
+			if !key.Comparable() {
+				return fmt.Errorf("map key of type %v is not comparable", key.Type())
+			}
+			kind, err = d.readKind()
+			if err != nil {
+				return err
+			}
+			elem := reflect.New(t.Elem()).Elem()
+			if err := d.decodeValue(kind, elem); err != nil {
+				return err
+			}
+			m.SetMapIndex(key, elem)
+		}
+		value.Set(m)
+		return nil
+
+	case reflect.Struct:
+		if kind != KindMap {
+			return mismatch(kind, t)
+		}
+		// zero fields are not encoded
+		value.SetZero()
+		fields := getFieldIndex(t)
+		for {
+			kind, err := d.readKind()
+			if err != nil {
+				return err
+			}
+			if kind == KindMapEnd {
+				break
+			}
+			if kind != KindString {
+				return fmt.Errorf("expected field name, got kind %v", kind[0])
+			}
+			name, err := d.readBytes()
+			if err != nil {
+				return err
+			}
+			kind, err = d.readKind()
+			if err != nil {
+				return err
+			}
+			index, ok := fields[string(name)]
+			if !ok {
+				// unknown field
+				if err := d.skip(kind); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := d.decodeValue(kind, value.FieldByIndex(index)); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	}
+
+	return fmt.Errorf("cannot decode into %v", t)
+}
+
+func (d *Decoder) decodeCycle(value reflect.Value) error {
+	distance, err := d.readInt()
+	if err != nil {
+		return err
+	}
+	if distance < 1 || distance > int64(len(d.ancestors)) {
+		return fmt.Errorf("invalid cycle distance: %d", distance)
+	}
+	target := d.ancestors[int64(len(d.ancestors))-distance]
+	if !target.Type().AssignableTo(value.Type()) {
+		return fmt.Errorf("cannot assign cyclic reference of type %v to %v", target.Type(), value.Type())
+	}
+	value.Set(target)
+	return nil
+}
+
+func (d *Decoder) decodeSpecial(kind Kind, value reflect.Value) error {
+	var decoded any
+	switch kind {
+
+	case KindTime:
+		sec, err := d.readInt()
+		if err != nil {
+			return err
+		}
+		nsec, err := d.readInt()
+		if err != nil {
+			return err
+		}
+		decoded = time.Unix(sec, nsec).UTC()
+
+	case KindDuration:
+		n, err := d.readInt()
+		if err != nil {
+			return err
+		}
+		decoded = time.Duration(n)
+
+	case KindBigInt:
+		n, err := d.readBigInt()
+		if err != nil {
+			return err
+		}
+		decoded = *n
+
+	case KindBigFloat:
+		bs, err := d.readBytes()
+		if err != nil {
+			return err
+		}
+		// 4 bits per hexadecimal mantissa digit
+		prec := uint(64)
+		if i := strings.IndexByte(string(bs), 'p'); i > 0 {
+			prec = max(prec, uint(i)*4)
+		}
+		f, _, err := new(big.Float).SetPrec(prec).Parse(string(bs), 0)
+		if err != nil {
+			return err
+		}
+		decoded = *f
+
+	case KindBigRat:
+		num, err := d.readBigInt()
+		if err != nil {
+			return err
+		}
+		denom, err := d.readBigInt()
+		if err != nil {
+			return err
+		}
+		if denom.Sign() == 0 {
+			return fmt.Errorf("zero denominator")
+		}
+		decoded = *new(big.Rat).SetFrac(num, denom)
+
+	case KindAddr:
+		bs, err := d.readBytes()
+		if err != nil {
+			return err
+		}
+		var addr netip.Addr
+		if err := addr.UnmarshalBinary(bs); err != nil {
+			return err
+		}
+		decoded = addr
+
+	case KindPrefix:
+		bs, err := d.readBytes()
+		if err != nil {
+			return err
+		}
+		var prefix netip.Prefix
+		if err := prefix.UnmarshalBinary(bs); err != nil {
+			return err
+		}
+		decoded = prefix
+
+	case KindAddrPort:
+		bs, err := d.readBytes()
+		if err != nil {
+			return err
+		}
+		var addrPort netip.AddrPort
+		if err := addrPort.UnmarshalBinary(bs); err != nil {
+			return err
+		}
+		decoded = addrPort
+
+	default:
+		return fmt.Errorf("unknown kind: %v", kind[0])
+	}
+
+	value.Set(reflect.ValueOf(decoded).Convert(value.Type()))
+	return nil
+}
+
+// skip discards an encoded value
+func (d *Decoder) skip(kind Kind) error {
+	var discard any
+	switch kind {
+	case KindList, KindMap:
+		end := KindListEnd
+		if kind == KindMap {
+			end = KindMapEnd
+		}
+		for {
+			kind, err := d.readKind()
+			if err != nil {
+				return err
+			}
+			if kind == end {
+				return nil
+			}
+			if err := d.skip(kind); err != nil {
+				return err
+			}
+		}
+	case KindCycle:
+		_, err := d.readInt()
+		return err
+	}
+	return d.decodeValue(kind, reflect.ValueOf(&discard).Elem())
+}
+
+var genericTypes = map[Kind]reflect.Type{
+	KindBool:     reflect.TypeFor[bool](),
+	KindInt:      reflect.TypeFor[int64](),
+	KindUint:     reflect.TypeFor[uint64](),
+	KindFloat:    reflect.TypeFor[float64](),
+	KindComplex:  reflect.TypeFor[complex128](),
+	KindString:   reflect.TypeFor[string](),
+	KindList:     reflect.TypeFor[[]any](),
+	KindMap:      reflect.TypeFor[map[any]any](),
+	KindTime:     timeType,
+	KindDuration: durationType,
+	KindBigInt:   reflect.PointerTo(bigIntType),
+	KindBigFloat: reflect.PointerTo(bigFloatType),
+	KindBigRat:   reflect.PointerTo(bigRatType),
+	KindAddr:     addrType,
+	KindPrefix:   prefixType,
+	KindAddrPort: addrPortType,
+}
+
+func genericType(kind Kind) (reflect.Type, error) {
+	t, ok := genericTypes[kind]
+	if !ok {
+		return nil, fmt.Errorf("unknown kind: %v", kind[0])
+	}
+	return t, nil
+}
+
+func mismatch(kind Kind, t reflect.Type) error {
+	return fmt.Errorf("cannot decode kind %v into %v", kind[0], t)
+}
+
+var fieldIndexes sync.Map // reflect.Type -> map[string][]int
+
+func getFieldIndex(t reflect.Type) map[string][]int {
+	v, ok := fieldIndexes.Load(t)
+	if ok {
+		return v.(map[string][]int)
+	}
+	index := make(map[string][]int)
+	for i := range t.NumField() {
+		field := t.Field(i)
+		if isSkipped(field) {
+			continue
+		}
+		index[field.Name] = field.Index
+	}
+	v, _ = fieldIndexes.LoadOrStore(t, index)
+	return v.(map[string][]int)
+}
@@ -0,0 +1,301 @@
+package dshash
+
+import (
+	"bytes"
+	"cmp"
+	"crypto/sha256"
+	"fmt"
+	"reflect"
+	"slices"
+	"unsafe"
+)
+
+// Equal reports whether a and b are structurally equal, that is, whether they
+// have the same hash.
+func Equal(a, b any) (bool, error) {
+	sumA, err := sum(addressable(reflect.ValueOf(a)))
+	if err != nil {
+		return false, err
+	}
+	sumB, err := sum(addressable(reflect.ValueOf(b)))
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(sumA, sumB), nil
+}
+
+// Difference is a location where two values differ. A or B is nil if the
+// location is absent in that value.
+type Difference struct {
+	Path string
+	A    any
+	B    any
+}
+
+func (d Difference) String() string {
+	path := d.Path
+	if path == "" {
+		path = "."
+	}
+	return fmt.Sprintf("%s: %v != %v", path, d.A, d.B)
+}
+
+// Diff returns the differences between a and b, following the same rules as
+// Hash: pointers and interfaces are transparent, zero struct fields and
+// unsupported values are absent, struct fields and map entries with equal
+// keys are compared with each other, and fields and entries are listed in
+// the order they are hashed in. Diff returns no differences iff Equal
+// returns true.
+func Diff(a, b any) ([]Difference, error) {
+	d := &differ{
+		visited: make(map[[2]unsafe.Pointer]struct{}),
+	}
+	valueA, valueB := addressable(reflect.ValueOf(a)), addressable(reflect.ValueOf(b))
+	if err := d.diff("", valueA, valueB); err != nil {
+		return nil, err
+	}
+	if len(d.differences) == 0 {
+		// values differing only in the shape of their cycles
+		equal, err := Equal(a, b)
+		if err != nil {
+			return nil, err
+		}
+		if !equal {
+			d.differences = append(d.differences, Difference{
+				A: exported(valueA),
+				B: exported(valueB),
+			})
+		}
+	}
+	return d.differences, nil
+}
+
+type differ struct {
+	differences []Difference
+	// pointer pairs on the current path
+	visited map[[2]unsafe.Pointer]struct{}
+}
+
+// diff compares lists and entries element by element and hashes the other
+// values only, so that each sub-value is hashed once.
+func (d *differ) diff(path string, a, b reflect.Value) error {
+	// cycles
+	pair := [2]unsafe.Pointer{pointerOf(a), pointerOf(b)}
+	if pair[0] != nil && pair[1] != nil {
+		if pair[0] == pair[1] && a.Type() == b.Type() {
+			// the same value
+			return nil
+		}
+		if _, ok := d.visited[pair]; ok {
+			return nil
+		}
+		d.visited[pair] = struct{}{}
+		defer delete(d.visited, pair)
+	}
+
+	a, b = unwrap(a), unwrap(b)
+	switch {
+
+	case isList(a) && isList(b):
+		for i := range max(a.Len(), b.Len()) {
+			elemPath := fmt.Sprintf("%s[%d]", path, i)
+			var elemA, elemB reflect.Value
+			if i < a.Len() {
+				elemA = a.Index(i)
+			}
+			if i < b.Len() {
+				elemB = b.Index(i)
+			}
+			if err := d.diff(elemPath, elemA, elemB); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case isEntries(a) && isEntries(b):
+		entriesA, err := entriesOf(a)
+		if err != nil {
+			return err
+		}
+		entriesB, err := entriesOf(b)
+		if err != nil {
+			return err
+		}
+		byStructFields := a.Kind() == reflect.Struct && b.Kind() == reflect.Struct
+		for _, key := range mergeEntryKeys(entriesA, entriesB, byStructFields) {
+			entryA, entryB := entriesA[key], entriesB[key]
+			entryPath := path
+			if entryA != nil {
+				entryPath += entryA.Path
+			} else {
+				entryPath += entryB.Path
+			}
+			var valueA, valueB reflect.Value
+			if entryA != nil {
+				valueA = entryA.Value
+			}
+			if entryB != nil {
+				valueB = entryB.Value
+			}
+			if err := d.diff(entryPath, valueA, valueB); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	}
+
+	sumA, err := sum(a)
+	if err != nil {
+		return err
+	}
+	sumB, err := sum(b)
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(sumA, sumB) {
+		return nil
+	}
+	d.differences = append(d.differences, Difference{
+		Path: path,
+		A:    exported(a),
+		B:    exported(b),
+	})
+	return nil
+}
+
+type _Entry struct {
+	Name  string
+	Path  string
+	Value reflect.Value
+}
+
+// entriesOf returns the struct fields or map entries of value, by key hash
+func entriesOf(value reflect.Value) (map[string]*_Entry, error) {
+	entries := make(map[string]*_Entry)
+
+	if value.Kind() == reflect.Struct {
+		for i := range value.NumField() {
+			field := value.Type().Field(i)
+			fieldValue := value.Field(i)
+			if isSkipped(field) || fieldValue.IsZero() || valueIsUnsupported(fieldValue) {
+				continue
+			}
+			keyHash, err := sum(reflect.ValueOf(field.Name))
+			if err != nil {
+				return nil, err
+			}
+			entries[string(keyHash)] = &_Entry{
+				Name:  field.Name,
+				Path:  "." + field.Name,
+				Value: fieldValue,
+			}
+		}
+		return entries, nil
+	}
+
+	iter := value.MapRange()
+	for iter.Next() {
+		keyHash, err := sum(iter.Key())
+		if err != nil {
+			return nil, err
+		}
+		entries[string(keyHash)] = &_Entry{
+			Path:  fmt.Sprintf("[%v]", exported(iter.Key())),
+			Value: iter.Value(),
+		}
+	}
+	return entries, nil
+}
+
+// mergeEntryKeys returns the keys of both entry sets, ordered by field name
+// for structs and by key hash otherwise
+func mergeEntryKeys(a, b map[string]*_Entry, byStructFields bool) []string {
+	union := make(map[string]*_Entry, len(a))
+	for key, entry := range a {
+		union[key] = entry
+	}
+	for key, entry := range b {
+		union[key] = entry
+	}
+	keys := make([]string, 0, len(union))
+	for key := range union {
+		keys = append(keys, key)
+	}
+	slices.SortFunc(keys, func(x, y string) int {
+		if byStructFields {
+			return cmp.Compare(union[x].Name, union[y].Name)
+		}
+		return cmp.Compare(x, y)
+	})
+	return keys
+}
+
+func sum(value reflect.Value) ([]byte, error) {
+	state := sha256.New()
+	if err := HashValue(newContext(state), value); err != nil {
+		return nil, err
+	}
+	return state.Sum(nil), nil
+}
+
+// unwrap strips pointers and interfaces, returning the invalid value for nil
+// and unsupported values
+func unwrap(value reflect.Value) reflect.Value {
+	for value.IsValid() && (value.Kind() == reflect.Pointer || value.Kind() == reflect.Interface) {
+		if value.IsNil() {
+			return reflect.Value{}
+		}
+		value = value.Elem()
+	}
+	if !value.IsValid() || isUnsupported(value.Type()) {
+		return reflect.Value{}
+	}
+	return value
+}
+
+func pointerOf(value reflect.Value) unsafe.Pointer {
+	for value.IsValid() && value.Kind() == reflect.Interface && !value.IsNil() {
+		value = value.Elem()
+	}
+	if value.IsValid() && value.Kind() == reflect.Pointer && !value.IsNil() {
+		return value.UnsafePointer()
+	}
+	return nil
+}
+
+func isList(value reflect.Value) bool {
+	if !value.IsValid() {
+		return false
+	}
+	if _, ok := specialType(value.Type()); ok {
+		return false
+	}
+	kind := value.Kind()
+	return (kind == reflect.Slice || kind == reflect.Array) && value.Type().Elem() != byteType
+}
+
+func isEntries(value reflect.Value) bool {
+	if !value.IsValid() {
+		return false
+	}
+	if _, ok := specialType(value.Type()); ok {
+		return false
+	}
+	kind := value.Kind()
+	return kind == reflect.Struct || kind == reflect.Map
+}
+
+// exported returns value as an interface, or nil if it is absent or not
+// accessible
+func exported(value reflect.Value) any {
+	switch {
+	case !value.IsValid():
+		return nil
+	case value.CanInterface():
+		return value.Interface()
+	case value.CanAddr():
+		return reflect.NewAt(value.Type(), unsafe.Pointer(value.UnsafeAddr())).Elem().Interface()
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package dshash
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestEqual(t *testing.T) {
+	for i, _case := range []struct {
+		a, b  any
+		equal bool
+	}{
+		{42, int8(42), true},
+		{42, uint(42), false},
+		{ptrTo(ptrTo("foo")), "foo", true},
+		{struct{ A, B int }{A: 1}, struct{ B, A int }{A: 1}, true},
+		{struct{ A int }{A: 1}, map[string]int{"A": 1}, true},
+		{[]int{1, 2}, []int{2, 1}, false},
+		{map[int]string{1: "a", 2: "b"}, map[int]string{2: "b", 1: "a"}, true},
+	} {
+		equal, err := Equal(_case.a, _case.b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if equal != _case.equal {
+			t.Fatalf("%d: expected %v", i+1, _case.equal)
+		}
+	}
+}
+
+type diffValue struct {
+	Name     string
+	Tags     []string
+	Attrs    map[string]any
+	Child    *diffValue
+	Disabled bool
+}
+
+func TestDiff(t *testing.T) {
+	a := &diffValue{
+		Name: "foo",
+		Tags: []string{"a", "b", "c"},
+		Attrs: map[string]any{
+			"x": 1,
+			"y": []int{1, 2},
+		},
+		Child: &diffValue{Name: "child"},
+	}
+	b := &diffValue{
+		Name: "foo",
+		Tags: []string{"a", "B"},
+		Attrs: map[string]any{
+			"y": []int{1, 3},
+			"z": "new",
+		},
+		Child:    &diffValue{Name: "CHILD"},
+		Disabled: true,
+	}
+
+	differences, err := Diff(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]string)
+	for _, difference := range differences {
+		got[difference.Path] = fmt.Sprintf("%v != %v", difference.A, difference.B)
+	}
+	expected := map[string]string{
+		".Attrs[x]":    "1 != <nil>",
+		".Attrs[y][1]": "2 != 3",
+		".Attrs[z]":    "<nil> != new",
+		".Child.Name":  "child != CHILD",
+		".Disabled":    "<nil> != true",
+		".Tags[1]":     "b != B",
+		".Tags[2]":     "c != <nil>",
+	}
+	if len(got) != len(expected) {
+		t.Fatalf("got %v", differences)
+	}
+	for path, difference := range expected {
+		if got[path] != difference {
+			t.Fatalf("%s: expected %s, got %s", path, difference, got[path])
+		}
+	}
+
+	// struct fields are listed in hash order
+	if !strings.HasPrefix(differences[0].Path, ".Attrs") {
+		t.Fatalf("unexpected order: %v", differences)
+	}
+	if differences[len(differences)-1].Path != ".Tags[2]" {
+		t.Fatalf("unexpected order: %v", differences)
+	}
+
+	differences, err = Diff(a, a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(differences) != 0 {
+		t.Fatalf("got %v", differences)
+	}
+}
+
+func TestDiffCycle(t *testing.T) {
+	// differing only in the label of a node in the cycle
+	a := &graphNode{Label: "a"}
+	a.Left = &graphNode{Label: "b", Left: a}
+	b := &graphNode{Label: "a"}
+	b.Left = &graphNode{Label: "c", Left: b}
+
+	differences, err := Diff(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(differences) != 1 || differences[0].Path != ".Left.Label" {
+		t.Fatalf("got %v", differences)
+	}
+
+	// differing only in the target of the cycle
+	c := &graphNode{Label: "a"}
+	c.Left = &graphNode{Label: "a"}
+	c.Left.Left = c.Left
+	d := &graphNode{Label: "a"}
+	d.Left = &graphNode{Label: "a", Left: d}
+	differences, err = Diff(c, d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(differences) == 0 {
+		t.Fatal("expected differences")
+	}
+}
+
+func TestDiffDeep(t *testing.T) {
+	// each level is hashed once, not once per enclosing level
+	const depth = 2000
+	chain := func(last string) *diffValue {
+		value := &diffValue{Name: last}
+		for range depth - 1 {
+			value = &diffValue{Name: "node", Child: value}
+		}
+		return value
+	}
+	differences, err := Diff(chain("a"), chain("b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := strings.Repeat(".Child", depth-1) + ".Name"
+	if len(differences) != 1 || differences[0].Path != path {
+		t.Fatalf("got %d differences", len(differences))
+	}
+}
@@ -1,28 +1,41 @@
 package dshash
 
 import (
-	"bytes"
 	"crypto/sha256"
 	"encoding/binary"
+	"math"
+	"math/big"
+	"net/netip"
 	"reflect"
-	"slices"
+	"time"
 )
 
 type Kind [1]byte
 
 var (
-	KindInvalid Kind = Kind{}
-	KindNil          = Kind{10}
-	KindBool         = Kind{20}
-	KindInt          = Kind{30}
-	KindUint         = Kind{40}
-	KindFloat        = Kind{50}
-	KindString       = Kind{60}
-	KindList         = Kind{70}
-	KindListEnd      = Kind{75}
-	KindMap          = Kind{80}
-	KindMapEnd       = Kind{85}
-	KindCycle        = Kind{90}
+	KindInvalid  Kind = Kind{}
+	KindNil           = Kind{10}
+	KindBool          = Kind{20}
+	KindInt           = Kind{30}
+	KindUint          = Kind{40}
+	KindFloat         = Kind{50}
+	KindString        = Kind{60}
+	KindList          = Kind{70}
+	KindListEnd       = Kind{75}
+	KindMap           = Kind{80}
+	KindMapEnd        = Kind{85}
+	KindCycle         = Kind{90}
+	KindComplex       = Kind{100}
+	KindTime          = Kind{110}
+	KindDuration      = Kind{120}
+	KindBigInt        = Kind{130}
+	KindBigFloat      = Kind{140}
+	KindBigRat        = Kind{150}
+	KindAddr          = Kind{160}
+	KindPrefix        = Kind{170}
+	KindAddrPort      = Kind{180}
+	KindListTree      = Kind{190}
+	KindMapTree       = Kind{200}
 )
 
 func encodeNil(ctx *Context) error {
@@ -76,7 +89,15 @@ func encodeUint(ctx *Context, n uint64) error {
 	return nil
 }
 
+// encodeFloat encodes -0 as 0 and all NaNs as the same NaN, so that floats
+// comparing equal, and NaNs, hash alike.
 func encodeFloat(ctx *Context, n float64) error {
+	switch {
+	case n == 0:
+		n = 0
+	case math.IsNaN(n):
+		n = math.NaN()
+	}
 	_, err := ctx.state.Write(KindFloat[:])
 	if err != nil {
 		return err
@@ -117,21 +138,16 @@ func encodeBytes(ctx *Context, value []byte) error {
 }
 
 func encodeList(ctx *Context, value reflect.Value, fn _HashFunc) error {
+	if ctx.parallel && value.Len() >= parallelThreshold {
+		return encodeListTree(ctx, value, fn)
+	}
 	_, err := ctx.state.Write(KindList[:])
 	if err != nil {
 		return err
 	}
 	for i, l := 0, value.Len(); i < l; i++ {
-		if fn != nil {
-			if err := fn(ctx, value.Index(i)); err != nil {
-				return err
-			}
-		} else {
-			// dynamic
-			elem := value.Index(i)
-			if err := getFunc(elem.Type())(ctx, elem); err != nil {
-				return err
-			}
+		if err := encodeElem(ctx, value.Index(i), fn); err != nil {
+			return err
 		}
 	}
 	_, err = ctx.state.Write(KindListEnd[:])
@@ -193,50 +209,49 @@ type _MapEntry struct {
 }
 
 func encodeMap(ctx *Context, value reflect.Value, keyFunc _HashFunc, valueFunc _HashFunc) error {
+	tree := ctx.parallel && value.Len() >= parallelThreshold
 	var entries []*_MapEntry
 	iter := value.MapRange()
 	for iter.Next() {
-		keyState := sha256.New()
-		if err := HashValue(&Context{
-			state:   keyState,
-			visited: ctx.visited,
-		}, iter.Key()); err != nil {
+		entry := &_MapEntry{
+			Key:   iter.Key(),
+			Value: iter.Value(),
+		}
+		if !tree {
+			keyState := sha256.New()
+			if err := HashValue(&Context{
+				state:    keyState,
+				visited:  ctx.visited,
+				depth:    ctx.depth,
+				parallel: ctx.parallel,
+			}, entry.Key); err != nil {
+				return err
+			}
+			entry.KeyHash = keyState.Sum(nil)
+		}
+		entries = append(entries, entry)
+	}
+	if tree {
+		if err := hashKeysParallel(ctx, entries); err != nil {
 			return err
 		}
-		entries = append(entries, &_MapEntry{
-			Key:     iter.Key(),
-			Value:   iter.Value(),
-			KeyHash: keyState.Sum(nil),
-		})
 	}
-	slices.SortStableFunc(entries, func(a, b *_MapEntry) int {
-		return bytes.Compare(a.KeyHash, b.KeyHash)
-	})
+	sortMapEntries(entries)
+
+	if tree {
+		return encodeMapTree(ctx, entries, keyFunc, valueFunc)
+	}
 
 	_, err := ctx.state.Write(KindMap[:])
 	if err != nil {
 		return err
 	}
 	for _, entry := range entries {
-		if keyFunc != nil {
-			if err := keyFunc(ctx, entry.Key); err != nil {
-				return err
-			}
-		} else {
-			// dynamic
-			if err := getFunc(entry.Key.Type())(ctx, entry.Key); err != nil {
-				return err
-			}
+		if err := encodeElem(ctx, entry.Key, keyFunc); err != nil {
+			return err
 		}
-		if valueFunc != nil {
-			if err := valueFunc(ctx, entry.Value); err != nil {
-				return err
-			}
-		} else {
-			// dynamic
-			if err := getFunc(entry.Value.Type())(ctx, entry.Value); err != nil {
-				return err
-			}
+		if err := encodeElem(ctx, entry.Value, valueFunc); err != nil {
+			return err
 		}
 	}
 	_, err = ctx.state.Write(KindMapEnd[:])
@@ -246,10 +261,150 @@ func encodeMap(ctx *Context, value reflect.Value, keyFunc _HashFunc, valueFunc _
 	return nil
 }
 
-func encodeCycle(ctx *Context) error {
+func encodeElem(ctx *Context, value reflect.Value, fn _HashFunc) error {
+	if fn != nil {
+		return fn(ctx, value)
+	}
+	// dynamic
+	return getFunc(value.Type())(ctx, value)
+}
+
+// encodeCycle encodes a back-reference to the pointer distance levels up the
+// current path, so that cycles to different ancestors are distinguished.
+func encodeCycle(ctx *Context, distance int) error {
 	_, err := ctx.state.Write(KindCycle[:])
 	if err != nil {
 		return err
 	}
+	err = binary.Write(ctx.state, binary.LittleEndian, int64(distance))
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func encodeComplex(ctx *Context, n complex128) error {
+	_, err := ctx.state.Write(KindComplex[:])
+	if err != nil {
+		return err
+	}
+	if err := binary.Write(ctx.state, binary.LittleEndian, real(n)); err != nil {
+		return err
+	}
+	if err := binary.Write(ctx.state, binary.LittleEndian, imag(n)); err != nil {
+		return err
+	}
+	return nil
+}
+
+func encodeTime(ctx *Context, t time.Time) error {
+	_, err := ctx.state.Write(KindTime[:])
+	if err != nil {
+		return err
+	}
+	// the instant only; location and monotonic reading are not part of the value
+	if err := binary.Write(ctx.state, binary.LittleEndian, t.Unix()); err != nil {
+		return err
+	}
+	if err := binary.Write(ctx.state, binary.LittleEndian, int64(t.Nanosecond())); err != nil {
+		return err
+	}
+	return nil
+}
+
+func encodeDuration(ctx *Context, d time.Duration) error {
+	_, err := ctx.state.Write(KindDuration[:])
+	if err != nil {
+		return err
+	}
+	err = binary.Write(ctx.state, binary.LittleEndian, int64(d))
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func encodeBigInt(ctx *Context, n *big.Int) error {
+	_, err := ctx.state.Write(KindBigInt[:])
+	if err != nil {
+		return err
+	}
+	return writeBigInt(ctx, n)
+}
+
+func encodeBigFloat(ctx *Context, f *big.Float) error {
+	_, err := ctx.state.Write(KindBigFloat[:])
+	if err != nil {
+		return err
+	}
+	// exact binary representation, independent of precision and rounding mode
+	return writeLengthPrefixed(ctx, []byte(f.Text('p', 0)))
+}
+
+func encodeBigRat(ctx *Context, r *big.Rat) error {
+	_, err := ctx.state.Write(KindBigRat[:])
+	if err != nil {
+		return err
+	}
+	// big.Rat is always normalized
+	if err := writeBigInt(ctx, r.Num()); err != nil {
+		return err
+	}
+	if err := writeBigInt(ctx, r.Denom()); err != nil {
+		return err
+	}
+	return nil
+}
+
+func encodeAddr(ctx *Context, addr netip.Addr) error {
+	_, err := ctx.state.Write(KindAddr[:])
+	if err != nil {
+		return err
+	}
+	bs, err := addr.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	return writeLengthPrefixed(ctx, bs)
+}
+
+func encodePrefix(ctx *Context, prefix netip.Prefix) error {
+	_, err := ctx.state.Write(KindPrefix[:])
+	if err != nil {
+		return err
+	}
+	bs, err := prefix.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	return writeLengthPrefixed(ctx, bs)
+}
+
+func encodeAddrPort(ctx *Context, addrPort netip.AddrPort) error {
+	_, err := ctx.state.Write(KindAddrPort[:])
+	if err != nil {
+		return err
+	}
+	bs, err := addrPort.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	return writeLengthPrefixed(ctx, bs)
+}
+
+func writeBigInt(ctx *Context, n *big.Int) error {
+	if err := binary.Write(ctx.state, binary.LittleEndian, int8(n.Sign())); err != nil {
+		return err
+	}
+	return writeLengthPrefixed(ctx, n.Bytes())
+}
+
+func writeLengthPrefixed(ctx *Context, value []byte) error {
+	if err := binary.Write(ctx.state, binary.LittleEndian, int64(len(value))); err != nil {
+		return err
+	}
+	if _, err := ctx.state.Write(value); err != nil {
+		return err
+	}
 	return nil
 }
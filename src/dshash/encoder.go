@@ -0,0 +1,35 @@
+package dshash
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"reflect"
+)
+
+// Encoder writes the canonical byte stream of values, the same bytes Hash
+// feeds into the hash state.
+type Encoder struct {
+	w *bufio.Writer
+}
+
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{
+		w: bufio.NewWriter(w),
+	}
+}
+
+func (e *Encoder) Encode(value any) error {
+	if err := HashValue(newContext(e.w), addressable(reflect.ValueOf(value))); err != nil {
+		return err
+	}
+	return e.w.Flush()
+}
+
+func Marshal(value any) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := NewEncoder(buf).Encode(value); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -3,9 +3,12 @@ package dshash
 import (
 	"cmp"
 	"fmt"
+	"math/big"
+	"net/netip"
 	"reflect"
 	"slices"
 	"sync"
+	"time"
 )
 
 type _HashFunc = func(ctx *Context, value reflect.Value) error
@@ -25,6 +28,10 @@ func makeFunc(t reflect.Type) _HashFunc {
 		}
 	}
 
+	if fn := makeSpecialFunc(t); fn != nil {
+		return fn
+	}
+
 	switch t.Kind() {
 
 	case reflect.Pointer:
@@ -33,12 +40,14 @@ func makeFunc(t reflect.Type) _HashFunc {
 				return encodeNil(ctx)
 			}
 			ptr := value.UnsafePointer()
-			if _, ok := ctx.visited[ptr]; ok {
-				return encodeCycle(ctx)
+			if depth, ok := ctx.visited[ptr]; ok {
+				return encodeCycle(ctx, ctx.depth-depth)
 			}
-			ctx.visited[ptr] = struct{}{}
+			ctx.visited[ptr] = ctx.depth
+			ctx.depth++
 			elemFunc := getFunc(t.Elem())
 			err := elemFunc(ctx, value.Elem())
+			ctx.depth--
 			delete(ctx.visited, ptr)
 			return err
 		}
@@ -48,7 +57,7 @@ func makeFunc(t reflect.Type) _HashFunc {
 			if value.IsNil() {
 				return encodeNil(ctx)
 			}
-			elem := value.Elem()
+			elem := addressable(value.Elem())
 			return getFunc(elem.Type())(ctx, elem)
 		}
 
@@ -72,6 +81,11 @@ func makeFunc(t reflect.Type) _HashFunc {
 			return encodeFloat(ctx, value.Float())
 		}
 
+	case reflect.Complex64, reflect.Complex128:
+		return func(ctx *Context, value reflect.Value) error {
+			return encodeComplex(ctx, value.Complex())
+		}
+
 	case reflect.String:
 		return func(ctx *Context, value reflect.Value) error {
 			return encodeString(ctx, value.String())
@@ -107,7 +121,7 @@ func makeFunc(t reflect.Type) _HashFunc {
 		var infos []*_FieldInfo
 		for i := range t.NumField() {
 			field := t.Field(i)
-			if isUnsupported(field.Type) {
+			if isSkipped(field) {
 				continue
 			}
 			infos = append(infos, &_FieldInfo{
@@ -141,6 +155,89 @@ func makeFunc(t reflect.Type) _HashFunc {
 	panic(fmt.Errorf("unknown type: %v", t))
 }
 
+// makeSpecialFunc returns the hash func for types with a dedicated encoding,
+// or nil if t is encoded structurally.
+func makeSpecialFunc(t reflect.Type) _HashFunc {
+	special, ok := specialType(t)
+	if !ok {
+		return nil
+	}
+
+	switch special {
+
+	case timeType:
+		return func(ctx *Context, value reflect.Value) error {
+			v, err := valueAs[time.Time](value)
+			if err != nil {
+				return err
+			}
+			return encodeTime(ctx, v)
+		}
+
+	case durationType:
+		return func(ctx *Context, value reflect.Value) error {
+			return encodeDuration(ctx, time.Duration(value.Int()))
+		}
+
+	case bigIntType:
+		return func(ctx *Context, value reflect.Value) error {
+			v, err := valueAs[big.Int](value)
+			if err != nil {
+				return err
+			}
+			return encodeBigInt(ctx, &v)
+		}
+
+	case bigFloatType:
+		return func(ctx *Context, value reflect.Value) error {
+			v, err := valueAs[big.Float](value)
+			if err != nil {
+				return err
+			}
+			return encodeBigFloat(ctx, &v)
+		}
+
+	case bigRatType:
+		return func(ctx *Context, value reflect.Value) error {
+			v, err := valueAs[big.Rat](value)
+			if err != nil {
+				return err
+			}
+			return encodeBigRat(ctx, &v)
+		}
+
+	case addrType:
+		return func(ctx *Context, value reflect.Value) error {
+			v, err := valueAs[netip.Addr](value)
+			if err != nil {
+				return err
+			}
+			return encodeAddr(ctx, v)
+		}
+
+	case prefixType:
+		return func(ctx *Context, value reflect.Value) error {
+			v, err := valueAs[netip.Prefix](value)
+			if err != nil {
+				return err
+			}
+			return encodePrefix(ctx, v)
+		}
+
+	case addrPortType:
+		return func(ctx *Context, value reflect.Value) error {
+			v, err := valueAs[netip.AddrPort](value)
+			if err != nil {
+				return err
+			}
+			return encodeAddrPort(ctx, v)
+		}
+
+	}
+
+	return nil
+}
+
 func getFunc(t reflect.Type) _HashFunc {
 	v, ok := funcs.Load(t)
 	if ok {
@@ -3,17 +3,12 @@ package dshash
 import (
 	"hash"
 	"reflect"
-	"unsafe"
 )
 
 // deterministic structural hashing
 
 func Hash(state hash.Hash, value any) error {
-	ctx := &Context{
-		state:   state,
-		visited: make(map[unsafe.Pointer]struct{}),
-	}
-	return HashValue(ctx, reflect.ValueOf(value))
+	return HashValue(newContext(state), addressable(reflect.ValueOf(value)))
 }
 
 func HashValue(ctx *Context, value reflect.Value) error {
@@ -4,7 +4,11 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
+	"math"
+	"math/big"
+	"net/netip"
 	"testing"
+	"time"
 )
 
 func ptrTo[T any](v T) *T {
@@ -258,6 +262,26 @@ func TestHashNil(t *testing.T) {
 	}
 }
 
+func TestHashFloat(t *testing.T) {
+	sum := func(f float64) string {
+		state := sha256.New()
+		if err := Hash(state, f); err != nil {
+			t.Fatal(err)
+		}
+		return hex.EncodeToString(state.Sum(nil))
+	}
+	if sum(math.Copysign(0, -1)) != sum(0) {
+		t.Fatal("-0 and 0 hash differently")
+	}
+	payload := math.Float64frombits(math.Float64bits(math.NaN()) | 1)
+	if sum(payload) != sum(math.NaN()) || sum(math.Copysign(math.NaN(), -1)) != sum(math.NaN()) {
+		t.Fatal("NaNs hash differently")
+	}
+	if sum(1) == sum(-1) {
+		t.Fatal("1 and -1 hash alike")
+	}
+}
+
 func TestHashCycle(t *testing.T) {
 	type P *P
 	var p P
@@ -428,3 +452,82 @@ func TestMapDeterminism(t *testing.T) {
 		}
 	}
 }
+
+func sumOf(t *testing.T, value any) string {
+	t.Helper()
+	state := sha256.New()
+	if err := Hash(state, value); err != nil {
+		t.Fatal(err)
+	}
+	return hex.EncodeToString(state.Sum(nil))
+}
+
+func TestHashSpecialTypes(t *testing.T) {
+	instant := time.Date(2024, 3, 1, 12, 0, 0, 42, time.UTC)
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		tokyo = time.FixedZone("JST", 9*3600)
+	}
+
+	type unexported struct {
+		at  time.Time
+		num big.Int
+	}
+
+	for i, _case := range []struct {
+		a, b  any
+		equal bool
+	}{
+		// complex
+		{complex64(1 + 2i), complex128(1 + 2i), true},
+		{complex128(1 + 2i), complex128(1 - 2i), false},
+		{complex128(42), float64(42), false},
+
+		// time.Time is hashed as an UTC instant
+		{instant, instant.In(tokyo), true},
+		{time.Now().Round(0), time.Now().Round(0).Add(time.Second), false},
+		{instant, instant.Add(time.Nanosecond), false},
+		{unexported{at: instant}, unexported{at: instant.In(tokyo)}, true},
+
+		// time.Duration
+		{time.Duration(42), int64(42), false},
+		{time.Second, 1000 * time.Millisecond, true},
+
+		// big numbers
+		{big.NewInt(42), *big.NewInt(42), true},
+		{big.NewInt(42), 42, false},
+		{big.NewInt(42), big.NewInt(-42), false},
+		{unexported{num: *big.NewInt(1)}, unexported{num: *big.NewInt(2)}, false},
+		{new(big.Float).SetPrec(10).SetInt64(3), new(big.Float).SetPrec(200).SetInt64(3), true},
+		{big.NewFloat(0.5), big.NewFloat(0.25), false},
+		{big.NewRat(1, 2), big.NewRat(2, 4), true},
+		{big.NewRat(1, 2), big.NewRat(-1, 2), false},
+		{big.NewRat(2, 1), big.NewInt(2), false},
+
+		// netip
+		{netip.MustParseAddr("10.0.0.1"), netip.MustParseAddr("10.0.0.1"), true},
+		{netip.MustParseAddr("10.0.0.1"), netip.MustParseAddr("::ffff:10.0.0.1"), false},
+		{netip.MustParsePrefix("10.0.0.0/8"), netip.MustParsePrefix("10.0.0.0/16"), false},
+		{netip.MustParseAddrPort("10.0.0.1:80"), netip.MustParseAddrPort("10.0.0.1:80"), true},
+		{netip.MustParseAddrPort("10.0.0.1:80"), netip.MustParseAddrPort("10.0.0.1:81"), false},
+		{netip.MustParseAddr("10.0.0.1"), netip.MustParseAddrPort("10.0.0.1:0"), false},
+	} {
+		if equal := sumOf(t, _case.a) == sumOf(t, _case.b); equal != _case.equal {
+			t.Fatalf("%d: %v vs %v: expected equal=%v", i+1, _case.a, _case.b, _case.equal)
+		}
+	}
+}
+
+func TestHashSkippedField(t *testing.T) {
+	type cached struct {
+		ID    string
+		cache *string `dshash:"-"`
+	}
+	value := "loaded"
+	if sumOf(t, cached{ID: "a"}) != sumOf(t, cached{ID: "a", cache: &value}) {
+		t.Fatal("skipped field changed the hash")
+	}
+	if sumOf(t, cached{ID: "a"}) != sumOf(t, struct{ ID string }{ID: "a"}) {
+		t.Fatal("skipped field changed the hash")
+	}
+}
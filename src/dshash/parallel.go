@@ -0,0 +1,177 @@
+package dshash
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"hash"
+	"maps"
+	"reflect"
+	"runtime"
+	"slices"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	// lists and maps with at least parallelThreshold elements are tree hashed
+	parallelThreshold = 1024
+	// number of elements or entries in each tree hashed chunk
+	parallelChunkSize = 256
+)
+
+// HashParallel is like Hash, but lists and maps with at least 1024 elements
+// are tree hashed on multiple goroutines.
+//
+// A tree hashed list is encoded as KindListTree, the element count as int64,
+// the sha256 digest of each consecutive chunk of 256 encoded elements, and
+// KindListEnd. A tree hashed map is encoded the same way with KindMapTree and
+// KindMapEnd, chunking the key-value pairs in the same order as Hash does.
+// Smaller values are encoded exactly as Hash encodes them.
+//
+// Chunk boundaries depend only on the value, so the digest is deterministic
+// and independent of GOMAXPROCS, but differs from the digest of Hash for
+// values containing large lists or maps.
+func HashParallel(state hash.Hash, value any) error {
+	ctx := newContext(state)
+	ctx.parallel = true
+	return HashValue(ctx, addressable(reflect.ValueOf(value)))
+}
+
+func encodeListTree(ctx *Context, value reflect.Value, fn _HashFunc) error {
+	length := value.Len()
+	digests, err := hashChunks(ctx, length, func(ctx *Context, i int) error {
+		return encodeElem(ctx, value.Index(i), fn)
+	})
+	if err != nil {
+		return err
+	}
+	return writeTree(ctx, KindListTree, KindListEnd, length, digests)
+}
+
+func encodeMapTree(ctx *Context, entries []*_MapEntry, keyFunc _HashFunc, valueFunc _HashFunc) error {
+	digests, err := hashChunks(ctx, len(entries), func(ctx *Context, i int) error {
+		if err := encodeElem(ctx, entries[i].Key, keyFunc); err != nil {
+			return err
+		}
+		return encodeElem(ctx, entries[i].Value, valueFunc)
+	})
+	if err != nil {
+		return err
+	}
+	return writeTree(ctx, KindMapTree, KindMapEnd, len(entries), digests)
+}
+
+func writeTree(ctx *Context, begin Kind, end Kind, length int, digests [][]byte) error {
+	_, err := ctx.state.Write(begin[:])
+	if err != nil {
+		return err
+	}
+	if err := binary.Write(ctx.state, binary.LittleEndian, int64(length)); err != nil {
+		return err
+	}
+	for _, digest := range digests {
+		if _, err := ctx.state.Write(digest); err != nil {
+			return err
+		}
+	}
+	_, err = ctx.state.Write(end[:])
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// hashChunks returns the sha256 digest of each chunk of n encoded elements
+func hashChunks(ctx *Context, n int, encode func(ctx *Context, i int) error) ([][]byte, error) {
+	numChunks := (n + parallelChunkSize - 1) / parallelChunkSize
+	digests := make([][]byte, numChunks)
+	err := parallelDo(numChunks, func(chunk int) error {
+		state := sha256.New()
+		chunkCtx := &Context{
+			state:    state,
+			visited:  maps.Clone(ctx.visited),
+			depth:    ctx.depth,
+			parallel: true,
+		}
+		for i := chunk * parallelChunkSize; i < min((chunk+1)*parallelChunkSize, n); i++ {
+			if err := encode(chunkCtx, i); err != nil {
+				return err
+			}
+		}
+		digests[chunk] = state.Sum(nil)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return digests, nil
+}
+
+// hashKeysParallel computes the sorting hashes of map keys on multiple goroutines
+func hashKeysParallel(ctx *Context, entries []*_MapEntry) error {
+	numChunks := (len(entries) + parallelChunkSize - 1) / parallelChunkSize
+	return parallelDo(numChunks, func(chunk int) error {
+		visited := maps.Clone(ctx.visited)
+		for _, entry := range entries[chunk*parallelChunkSize : min((chunk+1)*parallelChunkSize, len(entries))] {
+			keyState := sha256.New()
+			if err := HashValue(&Context{
+				state:    keyState,
+				visited:  visited,
+				depth:    ctx.depth,
+				parallel: true,
+			}, entry.Key); err != nil {
+				return err
+			}
+			entry.KeyHash = keyState.Sum(nil)
+		}
+		return nil
+	})
+}
+
+func sortMapEntries(entries []*_MapEntry) {
+	slices.SortStableFunc(entries, func(a, b *_MapEntry) int {
+		return bytes.Compare(a.KeyHash, b.KeyHash)
+	})
+}
+
+// parallelDo calls fn for each index in [0, n) on up to GOMAXPROCS goroutines
+// and returns the error of the lowest failed index.
+func parallelDo(n int, fn func(i int) error) error {
+	workers := min(runtime.GOMAXPROCS(0), n)
+	if workers <= 1 {
+		for i := range n {
+			if err := fn(i); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	errs := make([]error, n)
+	var next atomic.Int64
+	var failed atomic.Bool
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Go(func() {
+			for !failed.Load() {
+				i := int(next.Add(1) - 1)
+				if i >= n {
+					return
+				}
+				if err := fn(i); err != nil {
+					errs[i] = err
+					failed.Store(true)
+				}
+			}
+		})
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
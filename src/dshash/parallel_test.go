@@ -0,0 +1,83 @@
+package dshash
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"runtime"
+	"testing"
+)
+
+func parallelSumOf(t *testing.T, value any) string {
+	t.Helper()
+	state := sha256.New()
+	if err := HashParallel(state, value); err != nil {
+		t.Fatal(err)
+	}
+	return hex.EncodeToString(state.Sum(nil))
+}
+
+func TestHashParallel(t *testing.T) {
+	small := map[string][]int{
+		"foo": {1, 2, 3},
+	}
+	if parallelSumOf(t, small) != sumOf(t, small) {
+		t.Fatal("small values should hash the same as Hash")
+	}
+
+	list := make([]int, 5000)
+	for i := range list {
+		list[i] = i
+	}
+	m := make(map[string]any, 3000)
+	for i := range 3000 {
+		m[fmt.Sprintf("key-%d", i)] = []int{i}
+	}
+	type Nested struct {
+		List []int
+		Map  map[string]any
+	}
+	nested := &Nested{
+		List: list,
+		Map:  m,
+	}
+
+	for _, value := range []any{list, m, nested} {
+		if parallelSumOf(t, value) == sumOf(t, value) {
+			t.Fatal("tree hashed values should differ from Hash")
+		}
+
+		defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(0))
+		var expected string
+		for _, procs := range []int{1, 2, 8} {
+			runtime.GOMAXPROCS(procs)
+			sum := parallelSumOf(t, value)
+			if expected == "" {
+				expected = sum
+			} else if sum != expected {
+				t.Fatalf("digest depends on GOMAXPROCS: %s vs %s", expected, sum)
+			}
+		}
+	}
+
+	// the documented format
+	state := sha256.New()
+	state.Write(KindListTree[:])
+	binary.Write(state, binary.LittleEndian, int64(len(list)))
+	for start := 0; start < len(list); start += parallelChunkSize {
+		chunk := sha256.New()
+		for _, n := range list[start:min(start+parallelChunkSize, len(list))] {
+			data, err := Marshal(n)
+			if err != nil {
+				t.Fatal(err)
+			}
+			chunk.Write(data)
+		}
+		state.Write(chunk.Sum(nil))
+	}
+	state.Write(KindListEnd[:])
+	if sum := parallelSumOf(t, list); sum != hex.EncodeToString(state.Sum(nil)) {
+		t.Fatalf("unexpected tree hash %s", sum)
+	}
+}
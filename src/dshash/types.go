@@ -1,20 +1,72 @@
 package dshash
 
-import "reflect"
+import (
+	"fmt"
+	"math/big"
+	"net/netip"
+	"reflect"
+	"time"
+	"unsafe"
+)
 
 func isUnsupported(t reflect.Type) bool {
 	switch t.Kind() {
 	case reflect.Invalid,
 		reflect.Uintptr, reflect.UnsafePointer,
-		reflect.Complex64, reflect.Complex128,
 		reflect.Chan, reflect.Func:
 		return true
 	}
 	return false
 }
 
+// isSkipped reports whether a struct field is left out of hashes and
+// encodings: fields of unsupported types and fields tagged `dshash:"-"`, such
+// as caches.
+func isSkipped(field reflect.StructField) bool {
+	return isUnsupported(field.Type) || field.Tag.Get("dshash") == "-"
+}
+
 var byteType = reflect.TypeFor[byte]()
 
+var (
+	timeType     = reflect.TypeFor[time.Time]()
+	durationType = reflect.TypeFor[time.Duration]()
+	bigIntType   = reflect.TypeFor[big.Int]()
+	bigFloatType = reflect.TypeFor[big.Float]()
+	bigRatType   = reflect.TypeFor[big.Rat]()
+	addrType     = reflect.TypeFor[netip.Addr]()
+	prefixType   = reflect.TypeFor[netip.Prefix]()
+	addrPortType = reflect.TypeFor[netip.AddrPort]()
+)
+
+var specialKinds = map[reflect.Type]Kind{
+	timeType:     KindTime,
+	durationType: KindDuration,
+	bigIntType:   KindBigInt,
+	bigFloatType: KindBigFloat,
+	bigRatType:   KindBigRat,
+	addrType:     KindAddr,
+	prefixType:   KindPrefix,
+	addrPortType: KindAddrPort,
+}
+
+// specialType returns the type with a dedicated encoding that t is, or is
+// defined from, e.g. time.Time for `type Timestamp time.Time`.
+func specialType(t reflect.Type) (reflect.Type, bool) {
+	if _, ok := specialKinds[t]; ok {
+		return t, true
+	}
+	if t.Kind() == reflect.Struct {
+		for special := range specialKinds {
+			// only struct types defined from special have the same underlying type
+			if special.Kind() == reflect.Struct && t.ConvertibleTo(special) {
+				return special, true
+			}
+		}
+	}
+	return nil, false
+}
+
 func valueIsUnsupported(v reflect.Value) bool {
 	for (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) &&
 		!v.IsNil() {
@@ -22,3 +74,27 @@ func valueIsUnsupported(v reflect.Value) bool {
 	}
 	return isUnsupported(v.Type())
 }
+
+// addressable returns an addressable copy of a struct value, so that its
+// unexported fields can be read by valueAs.
+func addressable(value reflect.Value) reflect.Value {
+	if value.Kind() != reflect.Struct || value.CanAddr() || !value.CanInterface() {
+		return value
+	}
+	ptr := reflect.New(value.Type())
+	ptr.Elem().Set(value)
+	return ptr.Elem()
+}
+
+// valueAs returns the value as a T, including values reached through
+// unexported struct fields.
+func valueAs[T any](value reflect.Value) (T, error) {
+	if value.CanInterface() {
+		return value.Convert(reflect.TypeFor[T]()).Interface().(T), nil
+	}
+	if value.CanAddr() {
+		return *(*T)(unsafe.Pointer(value.UnsafeAddr())), nil
+	}
+	var zero T
+	return zero, fmt.Errorf("cannot access unexported value of type %v", value.Type())
+}
@@ -0,0 +1,125 @@
+package kvdb
+
+import (
+	"context"
+	"encoding/gob"
+	"fmt"
+	"io"
+
+	"github.com/ArborDB/arbordb/src/core"
+	"github.com/ArborDB/arbordb/src/storage"
+)
+
+const (
+	archiveFormat  = "arbordb-archive"
+	archiveVersion = 1
+)
+
+// archiveManifest starts an archive. The objects follow, children before
+// their parents.
+type archiveManifest struct {
+	Format  string
+	Version int
+	Root    core.Identifier
+	Objects int
+}
+
+// Export writes an archive of the objects reachable from a root, from which
+// Import restores them into any database.
+func (db *DB) Export(ctx context.Context, w io.Writer, rootID core.Identifier) error {
+	objects, err := db.reachable(db.context(ctx), rootID)
+	if err != nil {
+		return err
+	}
+	enc := gob.NewEncoder(w)
+	if err := enc.Encode(archiveManifest{
+		Format:  archiveFormat,
+		Version: archiveVersion,
+		Root:    rootID,
+		Objects: len(objects),
+	}); err != nil {
+		return fmt.Errorf("write manifest: %w", err)
+	}
+	for _, object := range objects {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := enc.Encode(object); err != nil {
+			return fmt.Errorf("write %v: %w", object.ID, err)
+		}
+	}
+	return nil
+}
+
+// Import stores the objects of an archive written by Export and returns its
+// root. Each object must hash to its identifier and refer only to objects
+// before it. The root of db is not changed, the imported root can be read
+// with Snapshot or pointed at by a branch or tag.
+func (db *DB) Import(ctx context.Context, r io.Reader) (core.Identifier, error) {
+	c := db.context(ctx)
+	dec := gob.NewDecoder(r)
+	var manifest archiveManifest
+	if err := dec.Decode(&manifest); err != nil {
+		return core.Identifier{}, fmt.Errorf("read manifest: %w", err)
+	}
+	if manifest.Format != archiveFormat || manifest.Version != archiveVersion {
+		return core.Identifier{}, fmt.Errorf("unsupported archive %q version %d", manifest.Format, manifest.Version)
+	}
+
+	imported := make(map[core.Identifier]bool)
+	for range manifest.Objects {
+		if err := ctx.Err(); err != nil {
+			return core.Identifier{}, err
+		}
+		var object syncObject
+		if err := dec.Decode(&object); err != nil {
+			return core.Identifier{}, fmt.Errorf("read object: %w", err)
+		}
+		for _, ref := range storage.References(object.Expr) {
+			if !imported[ref] {
+				return core.Identifier{}, fmt.Errorf("object %v refers to %v, not in the archive before it", object.ID, ref)
+			}
+		}
+		id, err := db.storage.Set(c, object.Expr)
+		if err != nil {
+			return core.Identifier{}, fmt.Errorf("store %v: %w", object.ID, err)
+		}
+		if id != object.ID {
+			return core.Identifier{}, fmt.Errorf("object %v hashes to %v", object.ID, id)
+		}
+		imported[id] = true
+	}
+	if manifest.Root.Key != "" && !imported[manifest.Root] {
+		return core.Identifier{}, fmt.Errorf("root %v not in the archive", manifest.Root)
+	}
+	return manifest.Root, nil
+}
+
+// reachable returns the objects reachable from a root, children before their
+// parents.
+func (db *DB) reachable(ctx *core.Context, rootID core.Identifier) ([]syncObject, error) {
+	var objects []syncObject
+	visited := make(map[core.Identifier]bool)
+	var visit func(id core.Identifier) error
+	visit = func(id core.Identifier) error {
+		if id.Key == "" || visited[id] {
+			return nil
+		}
+		visited[id] = true
+		var expr core.Expression
+		if err := db.storage.Get(ctx, id, &expr); err != nil {
+			return fmt.Errorf("load %v: %w", id, err)
+		}
+		for _, ref := range storage.References(expr) {
+			if err := visit(ref); err != nil {
+				return err
+			}
+		}
+		objects = append(objects, syncObject{ID: id, Expr: expr})
+		return nil
+	}
+	if err := visit(rootID); err != nil {
+		return nil, err
+	}
+	return objects, nil
+}
@@ -0,0 +1,117 @@
+package kvdb
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"maps"
+	"strings"
+	"testing"
+
+	"github.com/ArborDB/arbordb/src/core"
+	"github.com/ArborDB/arbordb/src/scalar"
+	"github.com/ArborDB/arbordb/src/storage"
+)
+
+func TestArchive(t *testing.T) {
+	ctx := context.Background()
+	db := New(storage.NewMemory(), core.Identifier{})
+	put(t, db, "a", "1", "b", "2")
+	branch, err := db.CreateBranch(ctx, "b", db.RootID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := branch.Update(ctx, func(tx *Tx) error {
+		return tx.Put("c", "3")
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// a branch head refers to its root and its previous head
+	headID, err := db.ref(ctx, branchPrefix, "b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var archive bytes.Buffer
+	if err := db.Export(ctx, &archive, headID); err != nil {
+		t.Fatal(err)
+	}
+
+	restored := New(storage.NewMemory(), core.Identifier{})
+	rootID, err := restored.Import(ctx, bytes.NewReader(archive.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rootID != headID {
+		t.Fatalf("got root %v, want %v", rootID, headID)
+	}
+	head, err := restored.loadCommit(restored.context(ctx), rootID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parent, err := restored.loadCommit(restored.context(ctx), head.Parent.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		root core.Identifier
+		want map[string]string
+	}{
+		{head.Root.ID, map[string]string{"/a": "1", "/b": "2", "/c": "3"}},
+		{parent.Root.ID, map[string]string{"/a": "1", "/b": "2"}},
+	} {
+		tx, err := restored.Snapshot(ctx, c.root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := make(map[string]string)
+		bucket, err := tx.Bucket(DefaultBucket)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for kv, err := range bucket.iterDict() {
+			if err != nil {
+				t.Fatal(err)
+			}
+			got["/"+string(kv.Key)] = kv.Value.String()
+		}
+		if !maps.Equal(got, c.want) {
+			t.Fatalf("got %v, want %v", got, c.want)
+		}
+	}
+}
+
+func TestArchiveCorrupted(t *testing.T) {
+	ctx := context.Background()
+	id := core.Identifier{Kind: "dshash-sha256", Key: "00"}
+	for _, c := range []struct {
+		name    string
+		objects []syncObject
+		want    string
+	}{
+		{"hash", []syncObject{{ID: id, Expr: scalar.String("x")}}, "hashes to"},
+		{"dangling", []syncObject{{ID: id, Expr: commitObject{Root: core.NewRef[core.Expression](id)}}}, "refers to"},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			var archive bytes.Buffer
+			enc := gob.NewEncoder(&archive)
+			if err := enc.Encode(archiveManifest{
+				Format:  archiveFormat,
+				Version: archiveVersion,
+				Root:    id,
+				Objects: len(c.objects),
+			}); err != nil {
+				t.Fatal(err)
+			}
+			for _, object := range c.objects {
+				if err := enc.Encode(object); err != nil {
+					t.Fatal(err)
+				}
+			}
+			db := New(storage.NewMemory(), core.Identifier{})
+			if _, err := db.Import(ctx, &archive); err == nil || !strings.Contains(err.Error(), c.want) {
+				t.Fatalf("expected %q, got %v", c.want, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,288 @@
+package kvdb
+
+import (
+	"errors"
+	"fmt"
+	"iter"
+	"slices"
+
+	"github.com/ArborDB/arbordb/src/collection"
+	"github.com/ArborDB/arbordb/src/core"
+	"github.com/ArborDB/arbordb/src/scalar"
+)
+
+// DefaultBucket is the bucket used by the key methods of Tx. It always exists.
+const DefaultBucket = ""
+
+var (
+	ErrBucketNotFound = errors.New("bucket not found")
+	ErrBucketExists   = errors.New("bucket already exists")
+)
+
+// Bucket is a named keyspace in a transaction.
+type Bucket struct {
+	tx        *Tx
+	name      string
+	base      collection.Dict[scalar.String, core.Expression]
+	mutations map[string]core.Expression // nil for deleted keys
+	// created in this transaction, replacing any previous bucket
+	created bool
+}
+
+// Bucket returns the named bucket.
+func (tx *Tx) Bucket(name string) (*Bucket, error) {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	if err := tx.check(false); err != nil {
+		return nil, err
+	}
+	return tx.bucket(name)
+}
+
+// CreateBucket creates a new empty bucket.
+func (tx *Tx) CreateBucket(name string) (*Bucket, error) {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	if err := tx.check(true); err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.bucket(name); err == nil {
+		return nil, fmt.Errorf("%w: %q", ErrBucketExists, name)
+	} else if !errors.Is(err, ErrBucketNotFound) {
+		return nil, err
+	}
+
+	bucket := &Bucket{
+		tx:        tx,
+		name:      name,
+		base:      make(collection.Map[scalar.String, core.Expression]),
+		mutations: make(map[string]core.Expression),
+		created:   true,
+	}
+	tx.buckets[name] = bucket
+	return bucket, nil
+}
+
+// DeleteBucket deletes a bucket and all its keys.
+func (tx *Tx) DeleteBucket(name string) error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	if err := tx.check(true); err != nil {
+		return err
+	}
+
+	if name == DefaultBucket {
+		return fmt.Errorf("cannot delete the default bucket")
+	}
+	if _, err := tx.bucket(name); err != nil {
+		return err
+	}
+	tx.buckets[name] = nil
+	return nil
+}
+
+// Buckets returns the names of all buckets in sorted order.
+func (tx *Tx) Buckets() ([]string, error) {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	if err := tx.check(false); err != nil {
+		return nil, err
+	}
+
+	tx.reads.buckets = true
+	var names []string
+	for kv, err := range tx.baseExpr.IterDict(tx.ctx) {
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := tx.buckets[string(kv.Key)]; !ok && !isReserved(string(kv.Key)) {
+			names = append(names, string(kv.Key))
+		}
+	}
+	for name, bucket := range tx.buckets {
+		if bucket != nil {
+			names = append(names, name)
+		}
+	}
+	if !slices.Contains(names, DefaultBucket) {
+		names = append(names, DefaultBucket)
+	}
+	slices.Sort(names)
+	return names, nil
+}
+
+func (tx *Tx) defaultBucket() (*Bucket, error) {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	if err := tx.check(false); err != nil {
+		return nil, err
+	}
+	return tx.bucket(DefaultBucket)
+}
+
+// bucket returns an opened bucket or opens it. tx.mu must be held.
+func (tx *Tx) bucket(name string) (*Bucket, error) {
+	if isReserved(name) {
+		return nil, fmt.Errorf("reserved bucket name: %q", name)
+	}
+	tx.reads.existence[name] = true
+	if bucket, ok := tx.buckets[name]; ok {
+		if bucket == nil {
+			return nil, fmt.Errorf("%w: %q", ErrBucketNotFound, name)
+		}
+		return bucket, nil
+	}
+
+	exists, err := tx.baseExpr.Exists(tx.ctx, scalar.String(name))
+	if err != nil {
+		return nil, err
+	}
+	var base collection.Dict[scalar.String, core.Expression]
+	switch {
+	case exists:
+		expr, err := tx.baseExpr.Get(tx.ctx, scalar.String(name))
+		if err != nil {
+			return nil, err
+		}
+		base, err = asBucket(name, expr)
+		if err != nil {
+			return nil, err
+		}
+		// loaded once, since a decoded DictRef does not cache
+		if ref, ok := base.(collection.DictRef[scalar.String, core.Expression]); ok {
+			if base, err = ref.Ref.Get(tx.ctx); err != nil {
+				return nil, fmt.Errorf("bucket %q: %w", name, err)
+			}
+		}
+	case name == DefaultBucket:
+		base = make(collection.Map[scalar.String, core.Expression])
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrBucketNotFound, name)
+	}
+
+	bucket := &Bucket{
+		tx:        tx,
+		name:      name,
+		base:      base,
+		mutations: make(map[string]core.Expression),
+	}
+	tx.buckets[name] = bucket
+	return bucket, nil
+}
+
+func (b *Bucket) Name() string {
+	return b.name
+}
+
+// Get returns the value of a key holding a scalar.String.
+func (b *Bucket) Get(key string) (string, error) {
+	val, err := GetAs[scalar.String](b, key)
+	if err != nil {
+		return "", err
+	}
+	return string(val), nil
+}
+
+var _ Getter = (*Bucket)(nil)
+
+func (b *Bucket) GetValue(key string) (core.Expression, error) {
+	b.tx.mu.Lock()
+	defer b.tx.mu.Unlock()
+	if err := b.checkOpen(false); err != nil {
+		return nil, err
+	}
+
+	b.tx.reads.addKey(b.name, key)
+	if val, ok := b.mutations[key]; ok {
+		if val == nil {
+			return nil, fmt.Errorf("key %v not found", key)
+		}
+		return val, nil
+	}
+
+	return b.base.Get(b.tx.ctx, scalar.String(key))
+}
+
+// Put sets the value of a key to a scalar.String.
+func (b *Bucket) Put(key string, value string) error {
+	return b.PutValue(key, scalar.String(value))
+}
+
+func (b *Bucket) PutValue(key string, value core.Expression) error {
+	if value == nil {
+		return fmt.Errorf("nil value for key %v", key)
+	}
+	b.tx.mu.Lock()
+	defer b.tx.mu.Unlock()
+	if err := b.checkOpen(true); err != nil {
+		return err
+	}
+	b.mutations[key] = value
+	return nil
+}
+
+func (b *Bucket) Delete(key string) error {
+	b.tx.mu.Lock()
+	defer b.tx.mu.Unlock()
+	if err := b.checkOpen(true); err != nil {
+		return err
+	}
+	b.mutations[key] = nil
+	return nil
+}
+
+// checkOpen reports whether the transaction allows the access, and whether
+// the bucket was deleted or replaced after it was opened. b.tx.mu must be
+// held.
+func (b *Bucket) checkOpen(write bool) error {
+	if err := b.tx.check(write); err != nil {
+		return err
+	}
+	if b.tx.buckets[b.name] != b {
+		return fmt.Errorf("%w: %q", ErrBucketNotFound, b.name)
+	}
+	return nil
+}
+
+// iterDict iterates the keys and values of the bucket with mutations
+// applied. b.tx.mu must be held.
+func (b *Bucket) iterDict() iter.Seq2[collection.KV[scalar.String, core.Expression], error] {
+	return func(yield func(collection.KV[scalar.String, core.Expression], error) bool) {
+		for kv, err := range b.base.IterDict(b.tx.ctx) {
+			if err != nil {
+				yield(kv, err)
+				return
+			}
+			if _, ok := b.mutations[string(kv.Key)]; ok {
+				continue
+			}
+			if !yield(kv, nil) {
+				return
+			}
+		}
+		for k, v := range b.mutations {
+			if v == nil {
+				continue
+			}
+			if !yield(collection.KV[scalar.String, core.Expression]{
+				Key:   scalar.String(k),
+				Value: v,
+			}, nil) {
+				return
+			}
+		}
+	}
+}
+
+// asBucket returns a bucket expression as a Dict, which is empty for nil.
+func asBucket(name string, expr core.Expression) (collection.Dict[scalar.String, core.Expression], error) {
+	if expr == nil {
+		return make(collection.Map[scalar.String, core.Expression]), nil
+	}
+	bucket, ok := expr.(collection.Dict[scalar.String, core.Expression])
+	if !ok {
+		return nil, fmt.Errorf("%w: bucket %q is not Dict[String, Expression], got %T", ErrInvalidRoot, name, expr)
+	}
+	return bucket, nil
+}
@@ -0,0 +1,212 @@
+package kvdb
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strconv"
+	"testing"
+
+	"github.com/ArborDB/arbordb/src/core"
+	"github.com/ArborDB/arbordb/src/scalar"
+	"github.com/ArborDB/arbordb/src/storage"
+)
+
+func TestBuckets(t *testing.T) {
+	db := New(storage.NewMemory(), core.Identifier{})
+	ctx := context.Background()
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.Bucket("users"); !errors.Is(err, ErrBucketNotFound) {
+		t.Fatalf("expected bucket not found, got %v", err)
+	}
+	users, err := tx.CreateBucket("users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.CreateBucket("users"); !errors.Is(err, ErrBucketExists) {
+		t.Fatalf("expected bucket exists, got %v", err)
+	}
+	orders, err := tx.CreateBucket("orders")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := users.Put("a", "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if err := orders.PutValue("a", scalar.Int(1)); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Put("a", "default"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	// keys are scoped to buckets
+	tx, err = db.Begin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names, err := tx.Buckets()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(names, []string{"", "orders", "users"}) {
+		t.Fatalf("got %q", names)
+	}
+	users, err = tx.Bucket("users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, err := users.Get("a"); err != nil || v != "alice" {
+		t.Fatalf("got %q, %v", v, err)
+	}
+	orders, err = tx.Bucket("orders")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, err := GetAs[scalar.Int](orders, "a"); err != nil || v != 1 {
+		t.Fatalf("got %v, %v", v, err)
+	}
+	if v, err := tx.Get("a"); err != nil || v != "default" {
+		t.Fatalf("got %q, %v", v, err)
+	}
+
+	// deleting a bucket
+	if err := tx.DeleteBucket("users"); err != nil {
+		t.Fatal(err)
+	}
+	if err := users.Put("b", "bob"); !errors.Is(err, ErrBucketNotFound) {
+		t.Fatalf("expected bucket not found, got %v", err)
+	}
+	if err := tx.DeleteBucket(DefaultBucket); err == nil {
+		t.Fatal("expected error deleting the default bucket")
+	}
+	// recreating it drops its keys
+	users, err = tx.CreateBucket("users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := users.Get("a"); err == nil {
+		t.Fatal("expected error for key of deleted bucket")
+	}
+	if err := tx.DeleteBucket("orders"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	tx, err = db.Begin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names, err = tx.Buckets()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(names, []string{"", "users"}) {
+		t.Fatalf("got %q", names)
+	}
+}
+
+func TestBucketsAtomicCommit(t *testing.T) {
+	db := New(storage.NewMemory(), core.Identifier{})
+	ctx := context.Background()
+
+	tx1, err := db.Begin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx2, err := db.Begin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"a", "b"} {
+		bucket, err := tx1.CreateBucket(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := bucket.Put("k", "v"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	bucket, err := tx2.CreateBucket("b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bucket.Put("k", "v2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx2.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx1.Commit(); !errors.Is(err, ErrConflict) {
+		t.Fatalf("expected conflict, got %v", err)
+	}
+
+	// no change of the conflicting transaction is visible
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names, err := tx.Buckets()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(names, []string{"", "b"}) {
+		t.Fatalf("got %q", names)
+	}
+	bucket, err = tx.Bucket("b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, err := bucket.Get("k"); err != nil || v != "v2" {
+		t.Fatalf("got %q, %v", v, err)
+	}
+}
+
+// loadCounter counts the loads of a Memory
+type loadCounter struct {
+	*storage.Memory
+	gets int
+}
+
+func (l *loadCounter) Get(ctx *core.Context, id core.Identifier, target any) error {
+	l.gets++
+	return l.Memory.Get(ctx, id, target)
+}
+
+func TestBucketLoadedOnce(t *testing.T) {
+	// buckets are decoded from the storage, not shared with the commits
+	backend := &loadCounter{Memory: storage.NewMemory()}
+	db := New(storage.NewCompressed(backend, storage.CompressOptions{}), core.Identifier{})
+	ctx := context.Background()
+	var kvs []string
+	for i := range 1000 {
+		kvs = append(kvs, strconv.Itoa(i), "v")
+	}
+	put(t, db, kvs...)
+
+	backend.gets = 0
+	if err := db.View(ctx, func(tx *Tx) error {
+		for i := range 100 {
+			if _, err := tx.Get(strconv.Itoa(i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	// the root and the bucket
+	if backend.gets != 2 {
+		t.Fatalf("loaded %d times", backend.gets)
+	}
+}
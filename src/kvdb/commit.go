@@ -0,0 +1,522 @@
+package kvdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/ArborDB/arbordb/src/collection"
+	"github.com/ArborDB/arbordb/src/core"
+	"github.com/ArborDB/arbordb/src/dshash"
+	"github.com/ArborDB/arbordb/src/scalar"
+)
+
+// maxCommitHistory is the number of commits whose writes are kept to detect
+// conflicts. Transactions begun before them conflict.
+const maxCommitHistory = 1024
+
+// accessSet is a set of keys read or written by a transaction.
+type accessSet struct {
+	// the list of buckets
+	buckets bool
+	// existence of buckets, or creation and deletion of buckets
+	existence map[string]bool
+	// all keys of buckets
+	scans map[string]bool
+	// keys of buckets
+	keys map[string]map[string]bool
+}
+
+func newAccessSet() *accessSet {
+	return &accessSet{
+		existence: make(map[string]bool),
+		scans:     make(map[string]bool),
+		keys:      make(map[string]map[string]bool),
+	}
+}
+
+func (s *accessSet) addKey(bucket string, key string) {
+	keys, ok := s.keys[bucket]
+	if !ok {
+		keys = make(map[string]bool)
+		s.keys[bucket] = keys
+	}
+	keys[key] = true
+}
+
+// conflicts reports whether writes changed anything in s. Writes of buckets
+// replaced the whole root.
+func (s *accessSet) conflicts(writes *accessSet) bool {
+	if writes.buckets {
+		return true
+	}
+	for name := range writes.existence {
+		if s.buckets || s.existence[name] || s.scans[name] || len(s.keys[name]) > 0 {
+			return true
+		}
+	}
+	for name, keys := range writes.keys {
+		if s.scans[name] && len(keys) > 0 {
+			return true
+		}
+		for key := range keys {
+			if s.keys[name][key] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// bucketOps are the changes of a transaction to a bucket.
+type bucketOps struct {
+	name      string
+	deleted   bool
+	created   bool
+	mutations map[string]core.Expression // nil for deleted keys
+}
+
+// ops returns the changes of the transaction. tx.mu must be held.
+func (tx *Tx) ops() []bucketOps {
+	var ops []bucketOps
+	for _, name := range slices.Sorted(maps.Keys(tx.buckets)) {
+		bucket := tx.buckets[name]
+		if bucket == nil {
+			ops = append(ops, bucketOps{
+				name:    name,
+				deleted: true,
+			})
+			continue
+		}
+		if !bucket.created && len(bucket.mutations) == 0 {
+			continue
+		}
+		ops = append(ops, bucketOps{
+			name:      name,
+			created:   bucket.created,
+			mutations: bucket.mutations,
+		})
+	}
+	return ops
+}
+
+// checkIndexes extracts the index entries of the values put by ops, so that
+// values rejected by an index fail their own transaction only, not the whole
+// batch.
+func checkIndexes(indexes map[string]Index, ops []bucketOps) error {
+	for _, op := range ops {
+		for _, name := range slices.Sorted(maps.Keys(indexes)) {
+			index := indexes[name]
+			if index.Bucket != op.name {
+				continue
+			}
+			for key, value := range op.mutations {
+				if value == nil {
+					continue
+				}
+				if _, err := extractEntries(index, key, value); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func writesOf(ops []bucketOps) *accessSet {
+	writes := newAccessSet()
+	for _, op := range ops {
+		if op.deleted || op.created {
+			writes.existence[op.name] = true
+		}
+		for key := range op.mutations {
+			writes.addKey(op.name, key)
+		}
+	}
+	return writes
+}
+
+type commitRequest struct {
+	baseSeq uint64
+	reads   *accessSet
+	ops     []bucketOps
+	logged  []walBucket
+	ctx     *core.Context
+	done    chan error
+}
+
+// commit queues a request and waits for its result. Whoever holds commitMu
+// commits all queued requests as one batch.
+func (db *DB) commit(req *commitRequest) error {
+	db.queueMu.Lock()
+	db.queue = append(db.queue, req)
+	db.queueMu.Unlock()
+
+	db.commitMu.Lock()
+	defer db.commitMu.Unlock()
+
+	select {
+	case err := <-req.done:
+		// committed by another leader
+		return err
+	default:
+	}
+
+	db.queueMu.Lock()
+	batch := db.queue
+	db.queue = nil
+	db.queueMu.Unlock()
+
+	db.commitBatch(batch, &core.Context{
+		Context:         context.WithoutCancel(req.ctx),
+		PhysicalStorage: db.storage,
+	})
+	return <-req.done
+}
+
+// commitBatch applies the non-conflicting requests in order to the latest
+// root and publishes the result as one new root. db.commitMu must be held.
+func (db *DB) commitBatch(batch []*commitRequest, ctx *core.Context) {
+	results := make([]error, len(batch))
+	err := func() error {
+		db.mu.RLock()
+		rootID := db.rootID
+		seq := db.seq
+		history := db.history
+		indexes := maps.Clone(db.indexes)
+		db.mu.RUnlock()
+
+		base, err := db.loadRoot(ctx, rootID)
+		if err != nil {
+			return err
+		}
+		builder := newRootBuilder(ctx, base)
+		var committed []*accessSet
+		var logged []walBucket
+	requests:
+		for i, req := range batch {
+			// history holds the commits after oldest
+			oldest := seq - uint64(len(history))
+			if req.baseSeq < oldest {
+				results[i] = ErrConflict
+				continue
+			}
+			for _, writes := range slices.Concat(history[req.baseSeq-oldest:], committed) {
+				if req.reads.conflicts(writes) {
+					results[i] = ErrConflict
+					continue requests
+				}
+			}
+			if len(req.ops) == 0 {
+				continue
+			}
+			if err := checkIndexes(indexes, req.ops); err != nil {
+				results[i] = err
+				continue
+			}
+			if err := builder.apply(req.ops); err != nil {
+				return err
+			}
+			committed = append(committed, writesOf(req.ops))
+			logged = append(logged, req.logged...)
+		}
+		if len(committed) == 0 {
+			return nil
+		}
+
+		newRoot, err := builder.build(indexes)
+		if err != nil {
+			return err
+		}
+		newID, err := db.storage.Set(ctx, newRoot)
+		if err != nil {
+			return fmt.Errorf("store: %w", err)
+		}
+		var record []byte
+		if db.wal != nil {
+			// the record refers to the values stored by walBuckets
+			if err := db.syncStorage(); err != nil {
+				return err
+			}
+			record, err = dshash.Marshal(walRecord{
+				Buckets:   logged,
+				Committed: newID,
+			})
+			if err != nil {
+				return fmt.Errorf("encode wal record: %w", err)
+			}
+		}
+		failpoint("commit:stored")
+
+		db.mu.Lock()
+		defer db.mu.Unlock()
+		if record != nil {
+			if err := db.wal.Append(record); err != nil {
+				return fmt.Errorf("append wal: %w", err)
+			}
+			failpoint("commit:logged")
+		}
+		db.publish(newID, committed)
+		return nil
+	}()
+
+	for i, req := range batch {
+		if results[i] == nil {
+			results[i] = err
+		}
+		req.done <- results[i]
+	}
+}
+
+// replaceRoot makes newID the root if the root is oldID, and returns
+// ErrConflict otherwise. Transactions begun before conflict.
+func (db *DB) replaceRoot(oldID core.Identifier, newID core.Identifier) error {
+	db.commitMu.Lock()
+	defer db.commitMu.Unlock()
+
+	var record []byte
+	if db.wal != nil {
+		// the new root is not replayed from the WAL
+		if err := db.syncStorage(); err != nil {
+			return err
+		}
+		var err error
+		record, err = dshash.Marshal(walRecord{
+			Root: newID,
+		})
+		if err != nil {
+			return fmt.Errorf("encode wal record: %w", err)
+		}
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if db.rootID != oldID {
+		return fmt.Errorf("%w: root is %v, not %v", ErrConflict, db.rootID, oldID)
+	}
+	if oldID == newID {
+		return nil
+	}
+	if record != nil {
+		if err := db.wal.Append(record); err != nil {
+			return fmt.Errorf("append wal: %w", err)
+		}
+	}
+	writes := newAccessSet()
+	writes.buckets = true
+	db.publish(newID, []*accessSet{writes})
+	return nil
+}
+
+// publish makes newID the root, written by the commits. db.mu must be held.
+func (db *DB) publish(newID core.Identifier, commits []*accessSet) {
+	db.rootID = newID
+	db.seq += uint64(len(commits))
+	db.history = append(db.history, commits...)
+	if len(db.history) > 2*maxCommitHistory {
+		db.history = slices.Clone(db.history[len(db.history)-maxCommitHistory:])
+	}
+	db.roots = append(db.roots, newID)
+	if len(db.roots) > 2*maxCommitHistory {
+		db.roots = slices.Clone(db.roots[len(db.roots)-maxCommitHistory:])
+	}
+	close(db.published)
+	db.published = make(chan struct{})
+}
+
+// rootBuilder applies bucket operations to a root.
+type rootBuilder struct {
+	ctx  *core.Context
+	base collection.Dict[scalar.String, core.Expression]
+	// changed buckets, nil for deleted buckets
+	buckets map[string]collection.Map[scalar.String, core.Expression]
+	// changed keys of the buckets of base, nil for buckets created or deleted
+	changed map[string]map[string]bool
+}
+
+func newRootBuilder(ctx *core.Context, base collection.Dict[scalar.String, core.Expression]) *rootBuilder {
+	return &rootBuilder{
+		ctx:     ctx,
+		base:    base,
+		buckets: make(map[string]collection.Map[scalar.String, core.Expression]),
+		changed: make(map[string]map[string]bool),
+	}
+}
+
+func (r *rootBuilder) apply(ops []bucketOps) error {
+	for _, op := range ops {
+		keys, ok := r.changed[op.name]
+		switch {
+		case op.deleted || op.created:
+			r.changed[op.name] = nil
+		case !ok:
+			keys = make(map[string]bool)
+			r.changed[op.name] = keys
+			fallthrough
+		case keys != nil:
+			for key := range op.mutations {
+				keys[key] = true
+			}
+		}
+		if op.deleted {
+			r.buckets[op.name] = nil
+			continue
+		}
+		var bucket collection.Map[scalar.String, core.Expression]
+		if op.created {
+			bucket = make(collection.Map[scalar.String, core.Expression])
+		} else {
+			var err error
+			bucket, err = r.bucket(op.name)
+			if err != nil {
+				return err
+			}
+		}
+		for key, value := range op.mutations {
+			if value == nil {
+				delete(bucket, scalar.String(key))
+			} else {
+				bucket[scalar.String(key)] = value
+			}
+		}
+		r.buckets[op.name] = bucket
+	}
+	return nil
+}
+
+// bucket returns a bucket materialized to a Map owned by r.
+func (r *rootBuilder) bucket(name string) (collection.Map[scalar.String, core.Expression], error) {
+	if bucket, ok := r.buckets[name]; ok {
+		if bucket == nil {
+			if name == DefaultBucket {
+				return make(collection.Map[scalar.String, core.Expression]), nil
+			}
+			return nil, fmt.Errorf("%w: %q", ErrBucketNotFound, name)
+		}
+		return bucket, nil
+	}
+
+	dict, err := r.baseBucket(name)
+	if err != nil {
+		return nil, err
+	}
+	if dict == nil {
+		if name == DefaultBucket {
+			return make(collection.Map[scalar.String, core.Expression]), nil
+		}
+		return nil, fmt.Errorf("%w: %q", ErrBucketNotFound, name)
+	}
+	var bucket collection.Map[scalar.String, core.Expression]
+	transform := collection.DictToMap[scalar.String, core.Expression]{}
+	if err := transform.Apply(r.ctx, dict, &bucket); err != nil {
+		return nil, fmt.Errorf("materialize: %w", err)
+	}
+	return bucket, nil
+}
+
+// baseBucket returns a bucket of the base root, or nil if missing.
+func (r *rootBuilder) baseBucket(name string) (collection.Dict[scalar.String, core.Expression], error) {
+	exists, err := r.base.Exists(r.ctx, scalar.String(name))
+	if err != nil || !exists {
+		return nil, err
+	}
+	expr, err := r.base.Get(r.ctx, scalar.String(name))
+	if err != nil {
+		return nil, err
+	}
+	return asBucket(name, expr)
+}
+
+// build returns the new root with the indexes of changed buckets updated.
+// Unregistered indexes are dropped, since they would go stale.
+func (r *rootBuilder) build(indexes map[string]Index) (collection.Map[scalar.String, core.Expression], error) {
+	var root collection.Map[scalar.String, core.Expression]
+	transform := collection.DictToMap[scalar.String, core.Expression]{}
+	if err := transform.Apply(r.ctx, r.base, &root); err != nil {
+		return nil, fmt.Errorf("materialize: %w", err)
+	}
+
+	for key := range root {
+		if isReserved(string(key)) && indexes[string(key)[len(indexPrefix):]].Name == "" {
+			delete(root, key)
+		}
+	}
+	for name, index := range indexes {
+		keys, changed := r.changed[index.Bucket]
+		expr, stored := root[scalar.String(indexPrefix+name)]
+		if stored && !changed {
+			continue
+		}
+		var entries collection.SortedArray[IndexEntry]
+		var err error
+		if stored && keys != nil {
+			base, ok := expr.(collection.SortedArray[IndexEntry])
+			if !ok {
+				return nil, fmt.Errorf("%w: index %q is not SortedArray[IndexEntry], got %T", ErrInvalidRoot, name, expr)
+			}
+			entries, err = r.updateIndex(index, base, keys)
+		} else {
+			entries, err = r.buildIndex(index)
+		}
+		if err != nil {
+			return nil, err
+		}
+		root[scalar.String(indexPrefix+name)] = entries
+	}
+
+	// buckets are stored apart from the root, to be loaded when used
+	for name, bucket := range r.buckets {
+		if bucket == nil {
+			delete(root, scalar.String(name))
+			continue
+		}
+		ref, err := collection.StoreDict(r.ctx, collection.Dict[scalar.String, core.Expression](bucket))
+		if err != nil {
+			return nil, fmt.Errorf("store bucket %q: %w", name, err)
+		}
+		root[scalar.String(name)] = ref
+	}
+	return root, nil
+}
+
+// buildIndex returns the entries of an index for all values of its bucket.
+func (r *rootBuilder) buildIndex(index Index) (collection.SortedArray[IndexEntry], error) {
+	bucket, err := r.bucket(index.Bucket)
+	if errors.Is(err, ErrBucketNotFound) {
+		bucket = nil
+	} else if err != nil {
+		return nil, err
+	}
+	return collectEntries(index, bucket.IterDict(r.ctx))
+}
+
+// updateIndex returns the stored entries of an index with the entries of the
+// changed keys of its bucket replaced.
+func (r *rootBuilder) updateIndex(index Index, base collection.SortedArray[IndexEntry], keys map[string]bool) (collection.SortedArray[IndexEntry], error) {
+	old, err := r.baseBucket(index.Bucket)
+	if err != nil {
+		return nil, err
+	}
+	bucket := r.buckets[index.Bucket]
+	changes := make(map[string]valueChange, len(keys))
+	for key := range keys {
+		var change valueChange
+		if old != nil {
+			exists, err := old.Exists(r.ctx, scalar.String(key))
+			if err != nil {
+				return nil, err
+			}
+			if exists {
+				change.old, err = old.Get(r.ctx, scalar.String(key))
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+		change.new = bucket[scalar.String(key)]
+		changes[key] = change
+	}
+	return updateEntries(index, base, changes)
+}
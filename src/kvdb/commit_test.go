@@ -0,0 +1,348 @@
+package kvdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ArborDB/arbordb/src/core"
+	"github.com/ArborDB/arbordb/src/storage"
+)
+
+type memoryWAL struct {
+	records [][]byte
+}
+
+var _ WAL = (*memoryWAL)(nil)
+
+func (m *memoryWAL) Append(record []byte) error {
+	m.records = append(m.records, record)
+	return nil
+}
+
+func (m *memoryWAL) Records() iter.Seq2[[]byte, error] {
+	return func(yield func([]byte, error) bool) {
+		for _, record := range m.records {
+			if !yield(record, nil) {
+				return
+			}
+		}
+	}
+}
+
+func (m *memoryWAL) Reset(record []byte) error {
+	m.records = [][]byte{record}
+	return nil
+}
+
+func TestCommitMerge(t *testing.T) {
+	db := New(storage.NewMemory(), core.Identifier{})
+	ctx := context.Background()
+
+	tx1, err := db.Begin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx2, err := db.Begin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tx1.Put("a", "1"); err != nil {
+		t.Fatal(err)
+	}
+	// blind writes do not conflict
+	if err := tx1.Put("c", "1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx2.Put("b", "2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx2.Put("c", "2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx1.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx2.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[string]string{
+		"a": "1",
+		"b": "2",
+		"c": "2",
+	} {
+		if v, err := tx.Get(key); err != nil || v != want {
+			t.Fatalf("%s: got %q, %v", key, v, err)
+		}
+	}
+}
+
+func TestCommitReadConflict(t *testing.T) {
+	db := New(storage.NewMemory(), core.Identifier{})
+	ctx := context.Background()
+
+	tx1, err := db.Begin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx2, err := db.Begin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx1.Get("a"); err == nil {
+		t.Fatal("expected error for missing key")
+	}
+	if err := tx1.Put("b", "1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx2.Put("a", "2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx2.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx1.Commit(); !errors.Is(err, ErrConflict) {
+		t.Fatalf("expected conflict, got %v", err)
+	}
+
+	// listing buckets conflicts with creating one
+	tx1, err = db.Begin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx2, err = db.Begin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx1.Buckets(); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx1.Put("b", "1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx2.CreateBucket("x"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx2.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx1.Commit(); !errors.Is(err, ErrConflict) {
+		t.Fatalf("expected conflict, got %v", err)
+	}
+}
+
+func TestGroupCommit(t *testing.T) {
+	wal := new(memoryWAL)
+	store := storage.NewMemory()
+	db, err := Open(context.Background(), store, wal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	// hold the leadership until all transactions are queued
+	const n = 20
+	db.commitMu.Lock()
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := range n {
+		tx, err := db.Begin(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := tx.Put(fmt.Sprintf("k%d", i), strconv.Itoa(i)); err != nil {
+			t.Fatal(err)
+		}
+		// every transaction reads k0, which the first one writes
+		if i > 0 {
+			if _, err := tx.Get("k0"); err == nil {
+				t.Fatal("expected error for missing key")
+			}
+		}
+		wg.Go(func() {
+			errs[i] = tx.Commit()
+		})
+		// queue in order
+		for {
+			db.queueMu.Lock()
+			queued := len(db.queue)
+			db.queueMu.Unlock()
+			if queued == i+1 {
+				break
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	db.commitMu.Unlock()
+	wg.Wait()
+
+	if errs[0] != nil {
+		t.Fatal(errs[0])
+	}
+	for _, err := range errs[1:] {
+		if !errors.Is(err, ErrConflict) {
+			t.Fatalf("expected conflict, got %v", err)
+		}
+	}
+	if len(wal.records) != 1 {
+		t.Fatalf("got %d wal records", len(wal.records))
+	}
+
+	// concurrent increments with retries
+	var counters sync.WaitGroup
+	for range n {
+		counters.Go(func() {
+			for {
+				tx, err := db.Begin(ctx)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				count := 0
+				if v, err := tx.Get("count"); err == nil {
+					count, _ = strconv.Atoi(v)
+				}
+				if err := tx.Put("count", strconv.Itoa(count+1)); err != nil {
+					t.Error(err)
+					return
+				}
+				err = tx.Commit()
+				if errors.Is(err, ErrConflict) {
+					continue
+				}
+				if err != nil {
+					t.Error(err)
+				}
+				return
+			}
+		})
+	}
+	counters.Wait()
+
+	// replaying the batches recovers the same state
+	for _, db := range []*DB{db, reopen(t, store, wal)} {
+		tx, err := db.Begin(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v, err := tx.Get("count"); err != nil || v != strconv.Itoa(n) {
+			t.Fatalf("got %q, %v", v, err)
+		}
+		if v, err := tx.Get("k0"); err != nil || v != "0" {
+			t.Fatalf("got %q, %v", v, err)
+		}
+	}
+}
+
+func reopen(t *testing.T, store core.PhysicalStorage, wal WAL) *DB {
+	t.Helper()
+	db, err := Open(context.Background(), store, wal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestCommitHistoryTrimmed(t *testing.T) {
+	db := New(storage.NewMemory(), core.Identifier{})
+	ctx := context.Background()
+
+	old, err := db.Begin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := old.Put("old", "1"); err != nil {
+		t.Fatal(err)
+	}
+	for i := range 2*maxCommitHistory + 1 {
+		tx, err := db.Begin(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := tx.Put("k", strconv.Itoa(i)); err != nil {
+			t.Fatal(err)
+		}
+		if err := tx.Commit(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(db.history) > 2*maxCommitHistory {
+		t.Fatalf("history of %d commits", len(db.history))
+	}
+	// the writes since old began are unknown
+	if err := old.Commit(); !errors.Is(err, ErrConflict) {
+		t.Fatalf("expected conflict, got %v", err)
+	}
+}
+
+func TestGroupCommitIndexRejected(t *testing.T) {
+	db := New(storage.NewMemory(), core.Identifier{})
+	if err := db.AddIndex(Index{
+		Name: "strict",
+		Extract: func(value core.Expression) ([]string, error) {
+			if value.String() == "bad" {
+				return nil, fmt.Errorf("bad value")
+			}
+			return []string{value.String()}, nil
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	// a rejected value fails its transaction only, not the batch
+	const n = 16
+	db.commitMu.Lock()
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := range n {
+		tx, err := db.Begin(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		value := strconv.Itoa(i)
+		if i == n/2 {
+			value = "bad"
+		}
+		if err := tx.Put(fmt.Sprintf("k%d", i), value); err != nil {
+			t.Fatal(err)
+		}
+		wg.Go(func() {
+			errs[i] = tx.Commit()
+		})
+	}
+	for {
+		db.queueMu.Lock()
+		queued := len(db.queue)
+		db.queueMu.Unlock()
+		if queued == n {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	db.commitMu.Unlock()
+	wg.Wait()
+
+	for i, err := range errs {
+		if (err != nil) != (i == n/2) {
+			t.Fatalf("transaction %d: got %v", i, err)
+		}
+	}
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keys := scanIndex(t, tx, "strict", KeyRange{}); len(keys) != n-1 {
+		t.Fatalf("got %v", keys)
+	}
+}
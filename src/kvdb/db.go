@@ -4,29 +4,119 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"maps"
+	"reflect"
 	"sync"
 
 	"github.com/ArborDB/arbordb/src/collection"
 	"github.com/ArborDB/arbordb/src/core"
 	"github.com/ArborDB/arbordb/src/scalar"
+	"github.com/ArborDB/arbordb/src/storage"
 )
 
 type DB struct {
 	storage core.PhysicalStorage
 	mu      sync.RWMutex
 	rootID  core.Identifier
+	indexes map[string]Index
+	wal     WAL
+	// number of commits
+	seq uint64
+	// writes of the last commits, up to seq
+	history []*accessSet
+	// roots published by the last batches of commits, up to rootID
+	roots []core.Identifier
+	// closed when a new root is published
+	published chan struct{}
+	// root the fork was created from, or last merged at
+	mergeBase core.Identifier
+	// stored refs of named branches and tags
+	refsID core.Identifier
+	// held by updates of refs
+	refsMu sync.Mutex
+
+	// held by the leader of group commits
+	commitMu sync.Mutex
+	queueMu  sync.Mutex
+	queue    []*commitRequest
 }
 
 func New(storage core.PhysicalStorage, rootID core.Identifier) *DB {
 	return &DB{
-		storage: storage,
-		rootID:  rootID,
+		storage:   storage,
+		rootID:    rootID,
+		published: make(chan struct{}),
 	}
 }
 
+// RootID returns the identifier of the current root.
+func (db *DB) RootID() core.Identifier {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	return db.rootID
+}
+
+// Begin starts a read-write transaction. It must end with Commit or
+// Rollback.
 func (db *DB) Begin(ctx context.Context) (*Tx, error) {
+	return db.begin(ctx, false)
+}
+
+// BeginReadOnly starts a transaction rejecting changes. It must end with
+// Rollback.
+func (db *DB) BeginReadOnly(ctx context.Context) (*Tx, error) {
+	return db.begin(ctx, true)
+}
+
+// View runs fn in a read-only transaction.
+func (db *DB) View(ctx context.Context, fn func(tx *Tx) error) error {
+	return view(ctx, db.BeginReadOnly, fn)
+}
+
+// Update runs fn in a read-write transaction, which is committed if fn
+// returns nil and rolled back otherwise.
+func (db *DB) Update(ctx context.Context, fn func(tx *Tx) error) error {
+	return update(ctx, db.Begin, fn)
+}
+
+func view(ctx context.Context, begin func(context.Context) (*Tx, error), fn func(tx *Tx) error) error {
+	tx, err := begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	return fn(tx)
+}
+
+func update(ctx context.Context, begin func(context.Context) (*Tx, error), fn func(tx *Tx) error) error {
+	tx, err := begin(ctx)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (db *DB) begin(ctx context.Context, readOnly bool) (*Tx, error) {
 	db.mu.RLock()
 	rootID := db.rootID
+	seq := db.seq
+	db.mu.RUnlock()
+	tx, err := db.beginAt(ctx, rootID, readOnly)
+	if err != nil {
+		return nil, err
+	}
+	tx.baseSeq = seq
+	return tx, nil
+}
+
+// beginAt starts a transaction over a root.
+func (db *DB) beginAt(ctx context.Context, rootID core.Identifier, readOnly bool) (*Tx, error) {
+	db.mu.RLock()
+	indexes := maps.Clone(db.indexes)
 	db.mu.RUnlock()
 
 	c := &core.Context{
@@ -34,108 +124,180 @@ func (db *DB) Begin(ctx context.Context) (*Tx, error) {
 		PhysicalStorage: db.storage,
 	}
 
-	var rootExpr collection.Dict[scalar.String, scalar.String]
-	if rootID.Key == "" {
-		rootExpr = make(collection.Map[scalar.String, scalar.String])
-	} else {
-		var expr core.Expression
-		if err := db.storage.Get(c, rootID, &expr); err != nil {
-			return nil, fmt.Errorf("load root: %w", err)
-		}
-		var ok bool
-		rootExpr, ok = expr.(collection.Dict[scalar.String, scalar.String])
-		if !ok {
-			return nil, fmt.Errorf("root expression is not Dict[String, String], got %T", expr)
-		}
+	rootExpr, err := db.loadRoot(c, rootID)
+	if err != nil {
+		return nil, err
 	}
 
 	return &Tx{
 		db:         db,
 		baseRootID: rootID,
 		baseExpr:   rootExpr,
-		mutations:  make(map[string]*string),
+		buckets:    make(map[string]*Bucket),
+		indexes:    indexes,
+		reads:      newAccessSet(),
+		readOnly:   readOnly,
 		ctx:        c,
 	}, nil
 }
 
+func (db *DB) loadRoot(ctx *core.Context, rootID core.Identifier) (collection.Dict[scalar.String, core.Expression], error) {
+	if rootID.Key == "" {
+		return make(collection.Map[scalar.String, core.Expression]), nil
+	}
+	rootExpr, err := storage.Load[collection.Dict[scalar.String, core.Expression]](ctx, db.storage, rootID)
+	if errors.Is(err, ErrTypeMismatch) {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidRoot, err)
+	} else if err != nil {
+		return nil, fmt.Errorf("load root: %w", err)
+	}
+	return rootExpr, nil
+}
+
+// Tx is a transaction over a snapshot of the database. The root of the
+// database is a Dict of bucket names to buckets, which are Dicts of keys to
+// values.
 type Tx struct {
 	db         *DB
 	baseRootID core.Identifier
-	baseExpr   collection.Dict[scalar.String, scalar.String]
-	mutations  map[string]*string
+	baseSeq    uint64
+	// branch committed to, nil for the root of db
+	branch     *Branch
+	baseCommit core.Identifier
+	baseExpr   collection.Dict[scalar.String, core.Expression]
+	buckets    map[string]*Bucket // opened buckets, nil for deleted buckets
+	indexes    map[string]Index
+	reads      *accessSet
+	readOnly   bool
+	done       bool
 	ctx        *core.Context
 	mu         sync.Mutex
 }
 
-func (tx *Tx) Get(key string) (string, error) {
-	tx.mu.Lock()
-	defer tx.mu.Unlock()
+var (
+	ErrInvalidRoot = errors.New("invalid root")
+	// the same as storage.ErrTypeMismatch, returned by loads of stored roots
+	ErrTypeMismatch = storage.ErrTypeMismatch
+	ErrReadOnly     = errors.New("read-only transaction")
+	ErrTxDone       = errors.New("transaction has already been committed or rolled back")
+)
 
-	if val, ok := tx.mutations[key]; ok {
-		if val == nil {
-			return "", fmt.Errorf("key %v not found", key)
-		}
-		return *val, nil
+// check reports whether the transaction is still usable, and writable if
+// write is true. tx.mu must be held.
+func (tx *Tx) check(write bool) error {
+	if tx.done {
+		return ErrTxDone
+	}
+	if write && tx.readOnly {
+		return ErrReadOnly
 	}
+	return nil
+}
 
-	val, err := tx.baseExpr.Get(tx.ctx, scalar.String(key))
+// Get returns the value of a key in the default bucket holding a
+// scalar.String.
+func (tx *Tx) Get(key string) (string, error) {
+	bucket, err := tx.defaultBucket()
 	if err != nil {
 		return "", err
 	}
-	return string(val), nil
+	return bucket.Get(key)
 }
 
+func (tx *Tx) GetValue(key string) (core.Expression, error) {
+	bucket, err := tx.defaultBucket()
+	if err != nil {
+		return nil, err
+	}
+	return bucket.GetValue(key)
+}
+
+// Put sets the value of a key in the default bucket to a scalar.String.
 func (tx *Tx) Put(key string, value string) error {
-	tx.mu.Lock()
-	defer tx.mu.Unlock()
-	tx.mutations[key] = &value
-	return nil
+	bucket, err := tx.defaultBucket()
+	if err != nil {
+		return err
+	}
+	return bucket.Put(key, value)
+}
+
+func (tx *Tx) PutValue(key string, value core.Expression) error {
+	bucket, err := tx.defaultBucket()
+	if err != nil {
+		return err
+	}
+	return bucket.PutValue(key, value)
 }
 
 func (tx *Tx) Delete(key string) error {
-	tx.mu.Lock()
-	defer tx.mu.Unlock()
-	tx.mutations[key] = nil
-	return nil
+	bucket, err := tx.defaultBucket()
+	if err != nil {
+		return err
+	}
+	return bucket.Delete(key)
+}
+
+type Getter interface {
+	GetValue(key string) (core.Expression, error)
+}
+
+var _ Getter = (*Tx)(nil)
+
+// GetAs returns the value of a key, which must be a T.
+func GetAs[T core.Expression](g Getter, key string) (T, error) {
+	var zero T
+	val, err := g.GetValue(key)
+	if err != nil {
+		return zero, err
+	}
+	typed, ok := val.(T)
+	if !ok {
+		return zero, fmt.Errorf("%w: key %v holds %T, not %v", ErrTypeMismatch, key, val, reflect.TypeFor[T]())
+	}
+	return typed, nil
 }
 
 var ErrConflict = errors.New("transaction conflict")
 
+// Commit applies the changes of the transaction to the latest root. It
+// returns ErrConflict if a transaction committed after Begin changed anything
+// read by this transaction.
 func (tx *Tx) Commit() error {
 	tx.mu.Lock()
 	defer tx.mu.Unlock()
-
-	// Materialize the base dict to a Map
-	var newMap collection.Map[scalar.String, scalar.String]
-	transform := collection.DictToMap[scalar.String, scalar.String]{}
-
-	if err := transform.Apply(tx.ctx, tx.baseExpr, &newMap); err != nil {
-		return fmt.Errorf("materialize: %w", err)
+	if err := tx.check(true); err != nil {
+		return err
 	}
-
-	// Apply mutations
-	for k, v := range tx.mutations {
-		if v == nil {
-			delete(newMap, scalar.String(k))
-		} else {
-			newMap[scalar.String(k)] = scalar.String(*v)
-		}
+	tx.done = true
+	if tx.branch != nil {
+		return tx.branch.commit(tx)
 	}
 
-	// Store the new Map
-	newID, err := tx.db.storage.Set(tx.ctx, newMap)
-	if err != nil {
-		return fmt.Errorf("store: %w", err)
+	req := &commitRequest{
+		baseSeq: tx.baseSeq,
+		reads:   tx.reads,
+		ops:     tx.ops(),
+		ctx:     tx.ctx,
+		done:    make(chan error, 1),
 	}
-
-	// Update DB root
-	tx.db.mu.Lock()
-	defer tx.db.mu.Unlock()
-
-	if tx.db.rootID != tx.baseRootID {
-		return ErrConflict
+	if tx.db.wal != nil {
+		logged, err := walBuckets(tx.ctx, tx.db.storage, req.ops)
+		if err != nil {
+			return err
+		}
+		req.logged = logged
 	}
+	return tx.db.commit(req)
+}
 
-	tx.db.rootID = newID
+// Rollback discards the changes of the transaction.
+func (tx *Tx) Rollback() error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	if err := tx.check(false); err != nil {
+		return err
+	}
+	tx.done = true
+	tx.buckets = nil
 	return nil
 }
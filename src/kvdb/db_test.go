@@ -2,10 +2,13 @@ package kvdb
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"testing"
 
+	"github.com/ArborDB/arbordb/src/collection"
 	"github.com/ArborDB/arbordb/src/core"
+	"github.com/ArborDB/arbordb/src/scalar"
 	"github.com/ArborDB/arbordb/src/storage"
 )
 
@@ -66,3 +69,183 @@ func TestLargeTransaction(t *testing.T) {
 		t.Fatalf("expected %s, got %s", expected, val)
 	}
 }
+
+func TestTypedValues(t *testing.T) {
+	store := storage.NewMemory()
+	db := New(store, core.Identifier{})
+
+	ctx := context.Background()
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := collection.Map[scalar.String, core.Expression]{
+		"name": scalar.String("foo"),
+		"tags": collection.Array[scalar.String]{"a", "b"},
+		"size": scalar.Int(42),
+	}
+	if err := tx.PutValue("doc", doc); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.PutValue("n", scalar.Int(1)); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Put("s", "str"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	tx, err = db.Begin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gotDoc, err := GetAs[collection.Dict[scalar.String, core.Expression]](tx, "doc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tags, err := gotDoc.Get(tx.ctx, "tags")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if length, _ := tags.(collection.Array[scalar.String]).Length(tx.ctx); length != 2 {
+		t.Fatalf("got %v", tags)
+	}
+	n, err := GetAs[scalar.Int](tx, "n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Fatalf("got %v", n)
+	}
+	if s, err := tx.Get("s"); err != nil || s != "str" {
+		t.Fatalf("got %v, %v", s, err)
+	}
+
+	if _, err := GetAs[scalar.String](tx, "n"); !errors.Is(err, ErrTypeMismatch) {
+		t.Fatalf("expected type mismatch, got %v", err)
+	}
+	if _, err := tx.Get("doc"); !errors.Is(err, ErrTypeMismatch) {
+		t.Fatalf("expected type mismatch, got %v", err)
+	}
+}
+
+func TestInvalidRoot(t *testing.T) {
+	store := storage.NewMemory()
+	rootID, err := store.Set(nil, scalar.Int(42))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := New(store, rootID)
+	_, err = db.Begin(context.Background())
+	if !errors.Is(err, ErrInvalidRoot) {
+		t.Fatalf("expected invalid root, got %v", err)
+	}
+	if !errors.Is(err, ErrTypeMismatch) {
+		t.Fatalf("expected type mismatch, got %v", err)
+	}
+}
+
+func TestReadOnly(t *testing.T) {
+	db := New(storage.NewMemory(), core.Identifier{})
+	ctx := context.Background()
+	if err := db.Update(ctx, func(tx *Tx) error {
+		return tx.Put("k", "v")
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.View(ctx, func(tx *Tx) error {
+		if v, err := tx.Get("k"); err != nil || v != "v" {
+			t.Fatalf("got %q, %v", v, err)
+		}
+		if err := tx.Put("k", "v2"); !errors.Is(err, ErrReadOnly) {
+			t.Fatalf("expected read-only, got %v", err)
+		}
+		if err := tx.Delete("k"); !errors.Is(err, ErrReadOnly) {
+			t.Fatalf("expected read-only, got %v", err)
+		}
+		if _, err := tx.CreateBucket("b"); !errors.Is(err, ErrReadOnly) {
+			t.Fatalf("expected read-only, got %v", err)
+		}
+		if err := tx.Commit(); !errors.Is(err, ErrReadOnly) {
+			t.Fatalf("expected read-only, got %v", err)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTxDone(t *testing.T) {
+	db := New(storage.NewMemory(), core.Identifier{})
+	ctx := context.Background()
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bucket, err := tx.CreateBucket("b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bucket.Put("k", "v"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Rollback(); !errors.Is(err, ErrTxDone) {
+		t.Fatalf("expected done, got %v", err)
+	}
+	if err := tx.Commit(); !errors.Is(err, ErrTxDone) {
+		t.Fatalf("expected done, got %v", err)
+	}
+	if _, err := tx.Get("k"); !errors.Is(err, ErrTxDone) {
+		t.Fatalf("expected done, got %v", err)
+	}
+	if _, err := bucket.Get("k"); !errors.Is(err, ErrTxDone) {
+		t.Fatalf("expected done, got %v", err)
+	}
+	if _, err := tx.Buckets(); !errors.Is(err, ErrTxDone) {
+		t.Fatalf("expected done, got %v", err)
+	}
+
+	// rolled back changes are discarded
+	if err := db.View(ctx, func(tx *Tx) error {
+		_, err := tx.Bucket("b")
+		return err
+	}); !errors.Is(err, ErrBucketNotFound) {
+		t.Fatalf("expected bucket not found, got %v", err)
+	}
+
+	// Update rolls back on error
+	failed := errors.New("failed")
+	if err := db.Update(ctx, func(tx *Tx) error {
+		if err := tx.Put("k", "v"); err != nil {
+			return err
+		}
+		return failed
+	}); !errors.Is(err, failed) {
+		t.Fatalf("expected failure, got %v", err)
+	}
+	if err := db.View(ctx, func(tx *Tx) error {
+		_, err := tx.Get("k")
+		return err
+	}); err == nil {
+		t.Fatal("expected error for rolled back key")
+	}
+
+	// committed transactions are done too
+	tx, err = db.Begin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Put("k", "v"); !errors.Is(err, ErrTxDone) {
+		t.Fatalf("expected done, got %v", err)
+	}
+}
@@ -0,0 +1,281 @@
+package kvdb
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"iter"
+	"slices"
+	"strings"
+
+	"github.com/ArborDB/arbordb/src/collection"
+	"github.com/ArborDB/arbordb/src/core"
+	"github.com/ArborDB/arbordb/src/scalar"
+)
+
+// Index is a secondary index over the values of a bucket. Index entries are
+// stored in the root next to the buckets and maintained by Tx.Commit.
+//
+// The entries of a stored index are only rebuilt when it is missing, so an
+// index whose Extract function changes must be registered under a new name.
+type Index struct {
+	Name   string
+	Bucket string
+	// Extract returns the index keys of a value
+	Extract func(value core.Expression) ([]string, error)
+}
+
+// IndexEntry maps an index key to the key of a value in the indexed bucket.
+type IndexEntry struct {
+	Key        scalar.String
+	PrimaryKey scalar.String
+}
+
+var _ core.Expression = IndexEntry{}
+
+func (e IndexEntry) String() string {
+	return fmt.Sprintf("(%v -> %v)", e.Key, e.PrimaryKey)
+}
+
+var _ core.Ordered[IndexEntry] = IndexEntry{}
+
+func (e IndexEntry) Compare(to IndexEntry) int {
+	return cmp.Or(
+		e.Key.Compare(to.Key),
+		e.PrimaryKey.Compare(to.PrimaryKey),
+	)
+}
+
+// KeyRange is the range of index keys k with Start <= k < End. An empty End
+// has no upper bound.
+type KeyRange struct {
+	Start string
+	End   string
+}
+
+// ExactKey returns the range containing only key.
+func ExactKey(key string) KeyRange {
+	return KeyRange{
+		Start: key,
+		End:   key + "\x00",
+	}
+}
+
+func (r KeyRange) contains(key string) bool {
+	return key >= r.Start && (r.End == "" || key < r.End)
+}
+
+var (
+	ErrIndexNotFound = errors.New("index not found")
+	ErrIndexExists   = errors.New("index already exists")
+)
+
+// indexPrefix prefixes the root keys of indexes. Bucket names must not start
+// with it.
+const indexPrefix = "\x00index/"
+
+// AddIndex registers an index for transactions begun afterwards. A missing
+// index is built by the first transaction committing it.
+func (db *DB) AddIndex(index Index) error {
+	if index.Name == "" || index.Extract == nil {
+		return fmt.Errorf("invalid index: %+v", index)
+	}
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if _, ok := db.indexes[index.Name]; ok {
+		return fmt.Errorf("%w: %q", ErrIndexExists, index.Name)
+	}
+	if db.indexes == nil {
+		db.indexes = make(map[string]Index)
+	}
+	db.indexes[index.Name] = index
+	return nil
+}
+
+// IndexScan returns the entries of an index in the range, in order, including
+// the changes of this transaction.
+func (tx *Tx) IndexScan(name string, keyRange KeyRange) iter.Seq2[IndexEntry, error] {
+	return func(yield func(IndexEntry, error) bool) {
+		index, ok := tx.indexes[name]
+		if !ok {
+			yield(IndexEntry{}, fmt.Errorf("%w: %q", ErrIndexNotFound, name))
+			return
+		}
+
+		tx.mu.Lock()
+		if err := tx.check(false); err != nil {
+			tx.mu.Unlock()
+			yield(IndexEntry{}, err)
+			return
+		}
+		tx.reads.existence[index.Bucket] = true
+		tx.reads.scans[index.Bucket] = true
+		entries, err := tx.indexEntries(index)
+		tx.mu.Unlock()
+		if err != nil {
+			yield(IndexEntry{}, err)
+			return
+		}
+
+		pos, err := entries.BinarySearch(tx.ctx, IndexEntry{
+			Key: scalar.String(keyRange.Start),
+		})
+		if err != nil {
+			yield(IndexEntry{}, err)
+			return
+		}
+		if pos < 0 {
+			pos = -pos - 1
+		}
+		for _, entry := range entries[pos:] {
+			if !keyRange.contains(string(entry.Key)) {
+				return
+			}
+			if !yield(entry, nil) {
+				return
+			}
+		}
+	}
+}
+
+// indexEntries returns the entries of an index with the mutations of this
+// transaction applied. tx.mu must be held.
+func (tx *Tx) indexEntries(index Index) (collection.SortedArray[IndexEntry], error) {
+	base, stored, err := tx.storedIndex(index.Name)
+	if err != nil {
+		return nil, err
+	}
+	bucket, opened := tx.buckets[index.Bucket]
+	if !stored || (opened && (bucket == nil || bucket.created)) {
+		return tx.buildIndex(index)
+	}
+	if !opened || len(bucket.mutations) == 0 {
+		return base, nil
+	}
+
+	changes := make(map[string]valueChange, len(bucket.mutations))
+	for key, value := range bucket.mutations {
+		change := valueChange{new: value}
+		exists, err := bucket.base.Exists(tx.ctx, scalar.String(key))
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			change.old, err = bucket.base.Get(tx.ctx, scalar.String(key))
+			if err != nil {
+				return nil, err
+			}
+		}
+		changes[key] = change
+	}
+	return updateEntries(index, base, changes)
+}
+
+// valueChange is the value of a key before and after a change, nil if
+// absent.
+type valueChange struct {
+	old core.Expression
+	new core.Expression
+}
+
+// updateEntries returns the entries of an index with the entries of the
+// changed keys replaced.
+func updateEntries(index Index, base collection.SortedArray[IndexEntry], changes map[string]valueChange) (collection.SortedArray[IndexEntry], error) {
+	removed := make(map[IndexEntry]bool)
+	var added []IndexEntry
+	for key, change := range changes {
+		if change.old != nil {
+			oldEntries, err := extractEntries(index, key, change.old)
+			if err != nil {
+				return nil, err
+			}
+			for _, entry := range oldEntries {
+				removed[entry] = true
+			}
+		}
+		if change.new != nil {
+			newEntries, err := extractEntries(index, key, change.new)
+			if err != nil {
+				return nil, err
+			}
+			added = append(added, newEntries...)
+		}
+	}
+
+	entries := make(collection.SortedArray[IndexEntry], 0, len(base)+len(added))
+	for _, entry := range base {
+		if !removed[entry] {
+			entries = append(entries, entry)
+		}
+	}
+	entries = append(entries, added...)
+	slices.SortFunc(entries, IndexEntry.Compare)
+	return slices.Compact(entries), nil
+}
+
+// storedIndex returns the entries of an index in the base root. tx.mu must
+// be held.
+func (tx *Tx) storedIndex(name string) (collection.SortedArray[IndexEntry], bool, error) {
+	exists, err := tx.baseExpr.Exists(tx.ctx, scalar.String(indexPrefix+name))
+	if err != nil || !exists {
+		return nil, false, err
+	}
+	expr, err := tx.baseExpr.Get(tx.ctx, scalar.String(indexPrefix+name))
+	if err != nil {
+		return nil, false, err
+	}
+	entries, ok := expr.(collection.SortedArray[IndexEntry])
+	if !ok {
+		return nil, false, fmt.Errorf("%w: index %q is not SortedArray[IndexEntry], got %T", ErrInvalidRoot, name, expr)
+	}
+	return entries, true, nil
+}
+
+// buildIndex returns the entries of an index for all values of its bucket.
+// tx.mu must be held.
+func (tx *Tx) buildIndex(index Index) (collection.SortedArray[IndexEntry], error) {
+	bucket, err := tx.bucket(index.Bucket)
+	if errors.Is(err, ErrBucketNotFound) {
+		return collection.SortedArray[IndexEntry]{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return collectEntries(index, bucket.iterDict())
+}
+
+// collectEntries returns the sorted entries of an index for the keys and
+// values of a bucket.
+func collectEntries(index Index, bucket iter.Seq2[collection.KV[scalar.String, core.Expression], error]) (collection.SortedArray[IndexEntry], error) {
+	entries := collection.SortedArray[IndexEntry]{}
+	for kv, err := range bucket {
+		if err != nil {
+			return nil, err
+		}
+		newEntries, err := extractEntries(index, string(kv.Key), kv.Value)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, newEntries...)
+	}
+	slices.SortFunc(entries, IndexEntry.Compare)
+	return slices.Compact(entries), nil
+}
+
+func extractEntries(index Index, key string, value core.Expression) ([]IndexEntry, error) {
+	indexKeys, err := index.Extract(value)
+	if err != nil {
+		return nil, fmt.Errorf("index %q: key %v: %w", index.Name, key, err)
+	}
+	entries := make([]IndexEntry, 0, len(indexKeys))
+	for _, indexKey := range indexKeys {
+		entries = append(entries, IndexEntry{
+			Key:        scalar.String(indexKey),
+			PrimaryKey: scalar.String(key),
+		})
+	}
+	return entries, nil
+}
+
+func isReserved(name string) bool {
+	return strings.HasPrefix(name, indexPrefix)
+}
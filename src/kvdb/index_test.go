@@ -0,0 +1,273 @@
+package kvdb
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/ArborDB/arbordb/src/core"
+	"github.com/ArborDB/arbordb/src/storage"
+)
+
+// cityIndex indexes values like "name@city" by city
+var cityIndex = Index{
+	Name:   "city",
+	Bucket: "users",
+	Extract: func(value core.Expression) ([]string, error) {
+		_, city, ok := strings.Cut(value.String(), "@")
+		if !ok {
+			return nil, nil
+		}
+		return []string{city}, nil
+	},
+}
+
+func scanIndex(t *testing.T, tx *Tx, name string, keyRange KeyRange) []string {
+	t.Helper()
+	var keys []string
+	for entry, err := range tx.IndexScan(name, keyRange) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys = append(keys, string(entry.Key)+"/"+string(entry.PrimaryKey))
+	}
+	return keys
+}
+
+func TestIndex(t *testing.T) {
+	db := New(storage.NewMemory(), core.Identifier{})
+	ctx := context.Background()
+
+	// existing values are indexed when the index is built
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	users, err := tx.CreateBucket("users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, value := range map[string]string{
+		"1": "alice@paris",
+		"2": "bob@berlin",
+		"3": "carol@paris",
+		"4": "dave",
+	} {
+		if err := users.Put(key, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.AddIndex(cityIndex); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AddIndex(cityIndex); !errors.Is(err, ErrIndexExists) {
+		t.Fatalf("expected index exists, got %v", err)
+	}
+
+	tx, err = db.Begin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := scanIndex(t, tx, "city", KeyRange{}); !slices.Equal(got, []string{"berlin/2", "paris/1", "paris/3"}) {
+		t.Fatalf("got %q", got)
+	}
+	if got := scanIndex(t, tx, "city", ExactKey("paris")); !slices.Equal(got, []string{"paris/1", "paris/3"}) {
+		t.Fatalf("got %q", got)
+	}
+	if got := scanIndex(t, tx, "city", KeyRange{Start: "a", End: "c"}); !slices.Equal(got, []string{"berlin/2"}) {
+		t.Fatalf("got %q", got)
+	}
+
+	// scans include uncommitted changes
+	users, err = tx.Bucket("users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := users.Put("1", "alice@rome"); err != nil {
+		t.Fatal(err)
+	}
+	if err := users.Delete("2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := users.Put("5", "erin@berlin"); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"berlin/5", "paris/3", "rome/1"}
+	if got := scanIndex(t, tx, "city", KeyRange{}); !slices.Equal(got, want) {
+		t.Fatalf("got %q", got)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	tx, err = db.Begin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := scanIndex(t, tx, "city", KeyRange{}); !slices.Equal(got, want) {
+		t.Fatalf("got %q", got)
+	}
+
+	// indexes are not buckets
+	names, err := tx.Buckets()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(names, []string{"", "users"}) {
+		t.Fatalf("got %q", names)
+	}
+	if _, err := tx.CreateBucket(indexPrefix + "city"); err == nil {
+		t.Fatal("expected error for reserved bucket name")
+	}
+
+	// deleting the bucket empties the index
+	if err := tx.DeleteBucket("users"); err != nil {
+		t.Fatal(err)
+	}
+	if got := scanIndex(t, tx, "city", KeyRange{}); len(got) != 0 {
+		t.Fatalf("got %q", got)
+	}
+
+	for _, err := range tx.IndexScan("foo", KeyRange{}) {
+		if !errors.Is(err, ErrIndexNotFound) {
+			t.Fatalf("expected index not found, got %v", err)
+		}
+	}
+}
+
+func TestIndexIncremental(t *testing.T) {
+	db := New(storage.NewMemory(), core.Identifier{})
+	ctx := context.Background()
+	extracted := 0
+	index := cityIndex
+	index.Extract = func(value core.Expression) ([]string, error) {
+		extracted++
+		return cityIndex.Extract(value)
+	}
+	if err := db.AddIndex(index); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Update(ctx, func(tx *Tx) error {
+		users, err := tx.CreateBucket("users")
+		if err != nil {
+			return err
+		}
+		for i := range 10 {
+			if err := users.Put(strconv.Itoa(i), "user@paris"); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// only the old and new values of the changed keys are extracted, the new
+	// ones twice as commits check them first
+	extracted = 0
+	if err := db.Update(ctx, func(tx *Tx) error {
+		users, err := tx.Bucket("users")
+		if err != nil {
+			return err
+		}
+		if err := users.Put("1", "user@rome"); err != nil {
+			return err
+		}
+		return users.Delete("2")
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if extracted != 4 {
+		t.Fatalf("extracted %d values", extracted)
+	}
+
+	tx, err := db.BeginReadOnly(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	if got := scanIndex(t, tx, "city", KeyRange{}); !slices.Equal(got, []string{"paris/0", "paris/3", "paris/4", "paris/5", "paris/6", "paris/7", "paris/8", "paris/9", "rome/1"}) {
+		t.Fatalf("got %q", got)
+	}
+	entries, _, err := tx.storedIndex("city")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rebuilt, err := tx.buildIndex(cityIndex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(entries, rebuilt) {
+		t.Fatalf("stored %v, rebuilt %v", entries, rebuilt)
+	}
+}
+
+func TestIndexAtomicCommit(t *testing.T) {
+	db := New(storage.NewMemory(), core.Identifier{})
+	if err := db.AddIndex(cityIndex); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	tx1, err := db.Begin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx2, err := db.Begin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	users, err := tx1.CreateBucket("users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := users.Put("1", "alice@paris"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx2.CreateBucket("users"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx2.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx1.Commit(); !errors.Is(err, ErrConflict) {
+		t.Fatalf("expected conflict, got %v", err)
+	}
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := scanIndex(t, tx, "city", KeyRange{}); len(got) != 0 {
+		t.Fatalf("got %q", got)
+	}
+
+	// extractor errors abort the commit
+	failing := Index{
+		Name:   "failing",
+		Bucket: DefaultBucket,
+		Extract: func(value core.Expression) ([]string, error) {
+			return nil, errors.New("boom")
+		},
+	}
+	if err := db.AddIndex(failing); err != nil {
+		t.Fatal(err)
+	}
+	tx, err = db.Begin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Put("k2", "v"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err == nil {
+		t.Fatal("expected extractor error")
+	}
+}
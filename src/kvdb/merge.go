@@ -0,0 +1,183 @@
+package kvdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+
+	"github.com/ArborDB/arbordb/src/collection"
+	"github.com/ArborDB/arbordb/src/core"
+	"github.com/ArborDB/arbordb/src/scalar"
+)
+
+// MergeConflict is a key changed differently by a fork and the database it
+// is merged into. Values are nil for absent keys.
+type MergeConflict struct {
+	Bucket string
+	Key    string
+	Base   core.Expression
+	Ours   core.Expression
+	Theirs core.Expression
+}
+
+// Resolver returns the merged value of a conflicting key, or nil to delete
+// it.
+type Resolver func(conflict MergeConflict) (core.Expression, error)
+
+// Fork returns an in-memory database over the same storage starting at the
+// current root, with the same indexes. Commits to the fork do not change db
+// until the fork is merged back with Merge.
+func (db *DB) Fork() *DB {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	fork := New(db.storage, db.rootID)
+	fork.indexes = maps.Clone(db.indexes)
+	fork.mergeBase = db.rootID
+	return fork
+}
+
+// Merge commits the changes of fork since it was created, or last merged,
+// onto the current root. Keys changed differently by both are resolved by
+// resolve, or fail the merge with collection.ErrMergeConflict if resolve is
+// nil. The merge is retried on conflicting commits, so resolve may see a
+// key more than once.
+func (db *DB) Merge(ctx context.Context, fork *DB, resolve Resolver) error {
+	fork.mu.RLock()
+	base := fork.mergeBase
+	theirs := fork.rootID
+	fork.mu.RUnlock()
+
+	for {
+		err := db.Update(ctx, func(tx *Tx) error {
+			return tx.merge(base, theirs, resolve)
+		})
+		if errors.Is(err, ErrConflict) && ctx.Err() == nil {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		break
+	}
+
+	fork.mu.Lock()
+	fork.mergeBase = theirs
+	fork.mu.Unlock()
+	return nil
+}
+
+// merge applies the changes of the buckets from the root base to the root
+// theirs onto the transaction, which must not have other changes.
+func (tx *Tx) merge(baseID core.Identifier, theirsID core.Identifier, resolve Resolver) error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	if err := tx.check(true); err != nil {
+		return err
+	}
+
+	baseRoot, err := tx.db.loadRoot(tx.ctx, baseID)
+	if err != nil {
+		return err
+	}
+	theirsRoot, err := tx.db.loadRoot(tx.ctx, theirsID)
+	if err != nil {
+		return err
+	}
+
+	for change, err := range collection.DiffDict(tx.ctx, baseRoot, theirsRoot) {
+		if err != nil {
+			return err
+		}
+		name := string(change.Key)
+		if isReserved(name) {
+			continue
+		}
+		if err := tx.mergeBucket(name, change.Old, change.New, resolve); err != nil {
+			return fmt.Errorf("bucket %q: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// mergeBucket merges the changes of a bucket from base to theirs, which are
+// nil for absent buckets. tx.mu must be held.
+func (tx *Tx) mergeBucket(name string, baseExpr core.Expression, theirsExpr core.Expression, resolve Resolver) error {
+	base, err := asBucket(name, baseExpr)
+	if err != nil {
+		return err
+	}
+	theirs, err := asBucket(name, theirsExpr)
+	if err != nil {
+		return err
+	}
+	bucket, err := tx.bucket(name)
+	if err != nil && !errors.Is(err, ErrBucketNotFound) {
+		return err
+	}
+	tx.reads.scans[name] = true
+	ours := collection.Dict[scalar.String, core.Expression](make(collection.Map[scalar.String, core.Expression]))
+	if bucket != nil {
+		ours = bucket.base
+	}
+
+	if theirsExpr == nil && name != DefaultBucket {
+		if bucket == nil {
+			// deleted by both
+			return nil
+		}
+		changed := false
+		for _, err := range collection.DiffDict(tx.ctx, base, ours) {
+			if err != nil {
+				return err
+			}
+			changed = true
+			break
+		}
+		if !changed {
+			tx.buckets[name] = nil
+			return nil
+		}
+		// changed by ours, keep the bucket with the conflicting keys resolved
+	}
+
+	merged, err := collection.Merge3(tx.ctx, base, ours, theirs, bucketResolver(name, resolve))
+	if err != nil {
+		return err
+	}
+
+	if bucket == nil {
+		// recreate the bucket deleted by ours, or created by theirs
+		bucket = &Bucket{
+			tx:        tx,
+			name:      name,
+			base:      make(collection.Map[scalar.String, core.Expression]),
+			mutations: make(map[string]core.Expression),
+			created:   true,
+		}
+		tx.buckets[name] = bucket
+	}
+	for change, err := range collection.DiffDict(tx.ctx, bucket.base, collection.Dict[scalar.String, core.Expression](merged)) {
+		if err != nil {
+			return err
+		}
+		bucket.mutations[string(change.Key)] = change.New
+	}
+	return nil
+}
+
+func bucketResolver(name string, resolve Resolver) collection.Resolver[scalar.String, core.Expression] {
+	if resolve == nil {
+		return nil
+	}
+	return func(ctx *core.Context, conflict collection.MergeConflict[scalar.String, core.Expression]) (core.Expression, bool, error) {
+		value, err := resolve(MergeConflict{
+			Bucket: name,
+			Key:    string(conflict.Key),
+			Base:   conflict.Base,
+			Ours:   conflict.Ours,
+			Theirs: conflict.Theirs,
+		})
+		return value, value != nil, err
+	}
+}
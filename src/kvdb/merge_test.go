@@ -0,0 +1,101 @@
+package kvdb
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"testing"
+
+	"github.com/ArborDB/arbordb/src/collection"
+	"github.com/ArborDB/arbordb/src/core"
+	"github.com/ArborDB/arbordb/src/scalar"
+	"github.com/ArborDB/arbordb/src/storage"
+)
+
+func TestMerge(t *testing.T) {
+	ctx := context.Background()
+	db := New(storage.NewMemory(), core.Identifier{})
+	if err := db.AddIndex(cityIndex); err != nil {
+		t.Fatal(err)
+	}
+	put(t, db, "a", "1", "b", "2", "c", "3")
+	if err := db.Update(ctx, func(tx *Tx) error {
+		for _, name := range []string{"users", "logs"} {
+			bucket, err := tx.CreateBucket(name)
+			if err != nil {
+				return err
+			}
+			if err := bucket.Put("alice", "alice@paris"); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	fork := db.Fork()
+	put(t, fork, "a", "10", "b", "20", "c", "")
+	if err := fork.Update(ctx, func(tx *Tx) error {
+		if err := tx.DeleteBucket("logs"); err != nil {
+			return err
+		}
+		bucket, err := tx.Bucket("users")
+		if err != nil {
+			return err
+		}
+		return bucket.Put("bob", "bob@tokyo")
+	}); err != nil {
+		t.Fatal(err)
+	}
+	put(t, db, "a", "10", "b", "200", "d", "4")
+
+	// the fork is not visible until merged
+	if got := dumpDB(t, db); got["/c"] != "3" || got["users/bob"] != "" {
+		t.Fatalf("got %v", got)
+	}
+
+	if err := db.Merge(ctx, fork, nil); !errors.Is(err, collection.ErrMergeConflict) {
+		t.Fatalf("expected conflict, got %v", err)
+	}
+	var conflicts []MergeConflict
+	if err := db.Merge(ctx, fork, func(conflict MergeConflict) (core.Expression, error) {
+		conflicts = append(conflicts, conflict)
+		return scalar.String(conflict.Ours.String() + conflict.Theirs.String()), nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(conflicts) != 1 || conflicts[0].Key != "b" || conflicts[0].Base != scalar.String("2") {
+		t.Fatalf("got conflicts %+v", conflicts)
+	}
+	want := map[string]string{
+		"/a":          "10",
+		"/b":          "20020",
+		"/d":          "4",
+		"users/alice": "alice@paris",
+		"users/bob":   "bob@tokyo",
+	}
+	if got := dumpDB(t, db); !maps.Equal(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if err := db.View(ctx, func(tx *Tx) error {
+		if got := scanIndex(t, tx, "city", ExactKey("tokyo")); len(got) != 1 {
+			t.Fatalf("got %q", got)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// merging again only applies the changes since the last merge
+	put(t, db, "a", "100")
+	put(t, fork, "e", "5")
+	if err := db.Merge(ctx, fork, nil); err != nil {
+		t.Fatal(err)
+	}
+	want["/a"] = "100"
+	want["/e"] = "5"
+	if got := dumpDB(t, db); !maps.Equal(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
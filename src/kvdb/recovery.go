@@ -0,0 +1,198 @@
+package kvdb
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ArborDB/arbordb/src/core"
+	"github.com/ArborDB/arbordb/src/dshash"
+)
+
+// walRecord is a record of the WAL. A checkpoint record sets the root and
+// the refs, a refs record sets the refs, a root record replaces the root, a
+// commit record holds the mutations of a batch of transactions in order. Values are stored before their
+// transaction is logged, so records refer to them by identifier.
+type walRecord struct {
+	Checkpoint bool
+	Root       core.Identifier
+	Refs       core.Identifier
+	Buckets    []walBucket
+	// the root the commit produced, to check the replay against
+	Committed core.Identifier
+}
+
+type walBucket struct {
+	Name    string
+	Deleted bool
+	Created bool
+	Puts    map[string]core.Identifier
+	Deletes []string
+}
+
+// Syncer is implemented by storages buffering writes.
+type Syncer interface {
+	// Sync makes all stored expressions durable.
+	Sync() error
+}
+
+// syncStorage makes the stored expressions durable, before logging records
+// referring to them.
+func (db *DB) syncStorage() error {
+	if syncer, ok := db.storage.(Syncer); ok {
+		if err := syncer.Sync(); err != nil {
+			return fmt.Errorf("sync storage: %w", err)
+		}
+	}
+	return nil
+}
+
+// failpoint is called at each step of commits and checkpoints at which a
+// crash leaves a distinct state. Tests replace it to simulate crashes.
+var failpoint = func(name string) {}
+
+// Open opens a database logging its commits to wal, recovering the root of
+// the last checkpoint and replaying the commits logged after it. The commits
+// are replayed with indexes registered, which must be the indexes registered
+// when they were committed.
+func Open(ctx context.Context, storage core.PhysicalStorage, wal WAL, indexes ...Index) (*DB, error) {
+	db := New(storage, core.Identifier{})
+	for _, index := range indexes {
+		if err := db.AddIndex(index); err != nil {
+			return nil, err
+		}
+	}
+	for data, err := range wal.Records() {
+		if err != nil {
+			return nil, fmt.Errorf("read wal: %w", err)
+		}
+		var record walRecord
+		if err := dshash.Unmarshal(data, &record); err != nil {
+			return nil, fmt.Errorf("decode wal record: %w", err)
+		}
+		if record.Checkpoint {
+			db.rootID = record.Root
+			db.refsID = record.Refs
+			continue
+		}
+		if record.Refs != (core.Identifier{}) {
+			db.refsID = record.Refs
+			continue
+		}
+		if record.Root != (core.Identifier{}) {
+			db.rootID = record.Root
+			continue
+		}
+		if err := db.replay(ctx, record); err != nil {
+			return nil, fmt.Errorf("replay: %w", err)
+		}
+	}
+	db.wal = wal
+	return db, nil
+}
+
+func (db *DB) replay(ctx context.Context, record walRecord) error {
+	c := &core.Context{
+		Context:         ctx,
+		PhysicalStorage: db.storage,
+	}
+	base, err := db.loadRoot(c, db.rootID)
+	if err != nil {
+		return err
+	}
+	ops, err := opsOf(c, db.storage, record.Buckets)
+	if err != nil {
+		return err
+	}
+	builder := newRootBuilder(c, base)
+	if err := builder.apply(ops); err != nil {
+		return err
+	}
+	root, err := builder.build(db.indexes)
+	if err != nil {
+		return err
+	}
+	rootID, err := db.storage.Set(c, root)
+	if err != nil {
+		return err
+	}
+	if record.Committed != (core.Identifier{}) && rootID != record.Committed {
+		return fmt.Errorf("replayed root %v, committed %v: indexes differ from those of the commit", rootID, record.Committed)
+	}
+	db.rootID = rootID
+	return nil
+}
+
+// walBuckets returns the logged form of bucket operations, storing their
+// values.
+func walBuckets(ctx *core.Context, storage core.PhysicalStorage, ops []bucketOps) ([]walBucket, error) {
+	var logged []walBucket
+	for _, op := range ops {
+		bucket := walBucket{
+			Name:    op.name,
+			Deleted: op.deleted,
+			Created: op.created,
+			Puts:    make(map[string]core.Identifier),
+		}
+		for key, value := range op.mutations {
+			if value == nil {
+				bucket.Deletes = append(bucket.Deletes, key)
+				continue
+			}
+			id, err := storage.Set(ctx, value)
+			if err != nil {
+				return nil, fmt.Errorf("store value of %v: %w", key, err)
+			}
+			bucket.Puts[key] = id
+		}
+		logged = append(logged, bucket)
+	}
+	return logged, nil
+}
+
+// opsOf returns the bucket operations of logged buckets, loading their
+// values.
+func opsOf(ctx *core.Context, storage core.PhysicalStorage, logged []walBucket) ([]bucketOps, error) {
+	var ops []bucketOps
+	for _, bucket := range logged {
+		op := bucketOps{
+			name:      bucket.Name,
+			deleted:   bucket.Deleted,
+			created:   bucket.Created,
+			mutations: make(map[string]core.Expression),
+		}
+		for key, id := range bucket.Puts {
+			var value core.Expression
+			if err := storage.Get(ctx, id, &value); err != nil {
+				return nil, fmt.Errorf("load value of %v: %w", key, err)
+			}
+			op.mutations[key] = value
+		}
+		for _, key := range bucket.Deletes {
+			op.mutations[key] = nil
+		}
+		ops = append(ops, op)
+	}
+	return ops, nil
+}
+
+// Checkpoint makes the current root durable and truncates the WAL to it.
+func (db *DB) Checkpoint() error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if db.wal == nil {
+		return nil
+	}
+	if err := db.syncStorage(); err != nil {
+		return err
+	}
+	failpoint("checkpoint:synced")
+	data, err := dshash.Marshal(walRecord{
+		Checkpoint: true,
+		Root:       db.rootID,
+		Refs:       db.refsID,
+	})
+	if err != nil {
+		return err
+	}
+	return db.wal.Reset(data)
+}
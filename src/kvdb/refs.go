@@ -0,0 +1,437 @@
+package kvdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/ArborDB/arbordb/src/collection"
+	"github.com/ArborDB/arbordb/src/core"
+	"github.com/ArborDB/arbordb/src/dshash"
+	"github.com/ArborDB/arbordb/src/scalar"
+	"github.com/ArborDB/arbordb/src/storage"
+)
+
+// Refs are named branches and tags, stored as a Map of names to identifiers.
+// A branch points to its head commit, a tag to a root.
+const (
+	branchPrefix = "branch/"
+	tagPrefix    = "tag/"
+)
+
+// refs maps the names of branches to their heads, and of tags to roots.
+type refs = collection.Map[scalar.String, core.Ref[core.Expression]]
+
+var (
+	ErrRefNotFound    = errors.New("ref not found")
+	ErrRefExists      = errors.New("ref already exists")
+	ErrNotFastForward = errors.New("not a fast-forward")
+)
+
+// commitObject is a head of a branch.
+type commitObject struct {
+	Root core.Ref[core.Expression]
+	// previous head, zero for the first head
+	Parent core.Ref[core.Expression]
+}
+
+var _ core.Expression = commitObject{}
+
+func (c commitObject) String() string {
+	return fmt.Sprintf("Commit(%v, %v)", c.Root.ID, c.Parent.ID)
+}
+
+// Branch is a named root of the database, independent of the root of db. Its
+// transactions commit by moving the branch to a new head, failing with
+// ErrConflict if another update of the branch happened after Begin.
+type Branch struct {
+	db   *DB
+	name string
+}
+
+// Branch returns the named branch, which may not exist yet.
+func (db *DB) Branch(name string) *Branch {
+	return &Branch{
+		db:   db,
+		name: name,
+	}
+}
+
+// CreateBranch creates a branch pointing at a root.
+func (db *DB) CreateBranch(ctx context.Context, name string, rootID core.Identifier) (*Branch, error) {
+	if name == "" {
+		return nil, fmt.Errorf("empty branch name")
+	}
+	branch := db.Branch(name)
+	if err := db.updateRefs(ctx, func(c *core.Context, refs refs) error {
+		key := scalar.String(branchPrefix + name)
+		if _, ok := refs[key]; ok {
+			return fmt.Errorf("%w: branch %q", ErrRefExists, name)
+		}
+		commitID, err := db.storage.Set(c, commitObject{Root: core.NewRef[core.Expression](rootID)})
+		if err != nil {
+			return err
+		}
+		refs[key] = core.NewRef[core.Expression](commitID)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return branch, nil
+}
+
+// DeleteBranch deletes a branch. The roots it pointed at are kept.
+func (db *DB) DeleteBranch(ctx context.Context, name string) error {
+	return db.deleteRef(ctx, branchPrefix, name)
+}
+
+// Branches returns the names of all branches in sorted order.
+func (db *DB) Branches(ctx context.Context) ([]string, error) {
+	return db.refNames(ctx, branchPrefix)
+}
+
+// CreateTag creates a tag pointing at a root. Tags cannot be moved.
+func (db *DB) CreateTag(ctx context.Context, name string, rootID core.Identifier) error {
+	if name == "" {
+		return fmt.Errorf("empty tag name")
+	}
+	return db.updateRefs(ctx, func(c *core.Context, refs refs) error {
+		key := scalar.String(tagPrefix + name)
+		if _, ok := refs[key]; ok {
+			return fmt.Errorf("%w: tag %q", ErrRefExists, name)
+		}
+		refs[key] = core.NewRef[core.Expression](rootID)
+		return nil
+	})
+}
+
+// Tag returns the root a tag points at.
+func (db *DB) Tag(ctx context.Context, name string) (core.Identifier, error) {
+	return db.ref(ctx, tagPrefix, name)
+}
+
+// Snapshot starts a read-only transaction over a root, such as one a tag
+// points at. It must end with Rollback.
+func (db *DB) Snapshot(ctx context.Context, rootID core.Identifier) (*Tx, error) {
+	return db.beginAt(ctx, rootID, true)
+}
+
+func (db *DB) DeleteTag(ctx context.Context, name string) error {
+	return db.deleteRef(ctx, tagPrefix, name)
+}
+
+// Tags returns the names of all tags in sorted order.
+func (db *DB) Tags(ctx context.Context) ([]string, error) {
+	return db.refNames(ctx, tagPrefix)
+}
+
+// RefsID returns the identifier of the stored refs, from which RestoreRefs
+// restores the branches and tags in a database not opened with a WAL.
+func (db *DB) RefsID() core.Identifier {
+	return db.currentRefsID()
+}
+
+// RestoreRefs replaces the branches and tags with the stored refs of refsID,
+// as returned by RefsID.
+func (db *DB) RestoreRefs(ctx context.Context, refsID core.Identifier) error {
+	return db.updateRefs(ctx, func(c *core.Context, current refs) error {
+		restored, err := db.loadRefs(c, refsID)
+		if err != nil {
+			return err
+		}
+		clear(current)
+		maps.Copy(current, restored)
+		return nil
+	})
+}
+
+func (b *Branch) Name() string {
+	return b.name
+}
+
+// Head returns the root the branch points at.
+func (b *Branch) Head(ctx context.Context) (core.Identifier, error) {
+	c := b.db.context(ctx)
+	_, head, err := b.head(c)
+	return head.Root.ID, err
+}
+
+// Begin starts a read-write transaction over the head of the branch. It must
+// end with Commit or Rollback.
+func (b *Branch) Begin(ctx context.Context) (*Tx, error) {
+	return b.begin(ctx, false)
+}
+
+// BeginReadOnly starts a transaction over the head of the branch rejecting
+// changes. It must end with Rollback.
+func (b *Branch) BeginReadOnly(ctx context.Context) (*Tx, error) {
+	return b.begin(ctx, true)
+}
+
+// View runs fn in a read-only transaction over the head of the branch.
+func (b *Branch) View(ctx context.Context, fn func(tx *Tx) error) error {
+	return view(ctx, b.BeginReadOnly, fn)
+}
+
+// Update runs fn in a read-write transaction over the head of the branch,
+// which is committed if fn returns nil and rolled back otherwise.
+func (b *Branch) Update(ctx context.Context, fn func(tx *Tx) error) error {
+	return update(ctx, b.Begin, fn)
+}
+
+// CompareAndSwap moves the branch to newRoot if it points at oldRoot, and
+// returns ErrConflict otherwise.
+func (b *Branch) CompareAndSwap(ctx context.Context, oldRoot core.Identifier, newRoot core.Identifier) error {
+	return b.db.updateRefs(ctx, func(c *core.Context, refs refs) error {
+		headID, head, err := b.headOf(c, refs)
+		if err != nil {
+			return err
+		}
+		if head.Root.ID != oldRoot {
+			return fmt.Errorf("%w: branch %q points at %v, not %v", ErrConflict, b.name, head.Root.ID, oldRoot)
+		}
+		if newRoot == oldRoot {
+			return nil
+		}
+		return b.moveTo(c, refs, newRoot, headID)
+	})
+}
+
+// FastForward moves the branch to the head of to, if the head of the branch
+// is a previous head of to. It returns ErrNotFastForward otherwise.
+func (b *Branch) FastForward(ctx context.Context, to *Branch) error {
+	if to.db != b.db {
+		return fmt.Errorf("branch %q is of another database", to.name)
+	}
+	return b.db.updateRefs(ctx, func(c *core.Context, refs refs) error {
+		headID, _, err := b.headOf(c, refs)
+		if err != nil {
+			return err
+		}
+		toID, _, err := to.headOf(c, refs)
+		if err != nil {
+			return err
+		}
+		for id := toID; id != headID; {
+			if id == (core.Identifier{}) {
+				return fmt.Errorf("%w: %q to %q", ErrNotFastForward, b.name, to.name)
+			}
+			commit, err := b.db.loadCommit(c, id)
+			if err != nil {
+				return err
+			}
+			id = commit.Parent.ID
+		}
+		refs[scalar.String(branchPrefix+b.name)] = core.NewRef[core.Expression](toID)
+		return nil
+	})
+}
+
+// Promote makes the head of the branch the root of the database if the root
+// is oldRoot, and returns ErrConflict otherwise. The branch cannot move
+// meanwhile.
+func (b *Branch) Promote(ctx context.Context, oldRoot core.Identifier) error {
+	b.db.refsMu.Lock()
+	defer b.db.refsMu.Unlock()
+	_, head, err := b.head(b.db.context(ctx))
+	if err != nil {
+		return err
+	}
+	return b.db.replaceRoot(oldRoot, head.Root.ID)
+}
+
+func (b *Branch) begin(ctx context.Context, readOnly bool) (*Tx, error) {
+	headID, head, err := b.head(b.db.context(ctx))
+	if err != nil {
+		return nil, err
+	}
+	tx, err := b.db.beginAt(ctx, head.Root.ID, readOnly)
+	if err != nil {
+		return nil, err
+	}
+	tx.branch = b
+	tx.baseCommit = headID
+	return tx, nil
+}
+
+// commit moves the branch to a new head with the changes of tx. tx.mu must
+// be held.
+func (b *Branch) commit(tx *Tx) error {
+	ops := tx.ops()
+	if len(ops) == 0 {
+		return nil
+	}
+	return b.db.updateRefs(tx.ctx, func(c *core.Context, refs refs) error {
+		headID, _, err := b.headOf(c, refs)
+		if err != nil {
+			return err
+		}
+		if headID != tx.baseCommit {
+			return fmt.Errorf("%w: branch %q moved", ErrConflict, b.name)
+		}
+		builder := newRootBuilder(c, tx.baseExpr)
+		if err := builder.apply(ops); err != nil {
+			return err
+		}
+		root, err := builder.build(tx.indexes)
+		if err != nil {
+			return err
+		}
+		rootID, err := b.db.storage.Set(c, root)
+		if err != nil {
+			return fmt.Errorf("store: %w", err)
+		}
+		return b.moveTo(c, refs, rootID, headID)
+	})
+}
+
+// moveTo stores a new head of the branch pointing at rootID, after headID.
+func (b *Branch) moveTo(ctx *core.Context, refs refs, rootID core.Identifier, headID core.Identifier) error {
+	commitID, err := b.db.storage.Set(ctx, commitObject{
+		Root:   core.NewRef[core.Expression](rootID),
+		Parent: core.NewRef[core.Expression](headID),
+	})
+	if err != nil {
+		return err
+	}
+	refs[scalar.String(branchPrefix+b.name)] = core.NewRef[core.Expression](commitID)
+	return nil
+}
+
+func (b *Branch) head(ctx *core.Context) (core.Identifier, commitObject, error) {
+	refs, err := b.db.loadRefs(ctx, b.db.currentRefsID())
+	if err != nil {
+		return core.Identifier{}, commitObject{}, err
+	}
+	return b.headOf(ctx, refs)
+}
+
+// headOf returns the head of the branch in refs and its identifier.
+func (b *Branch) headOf(ctx *core.Context, refs refs) (core.Identifier, commitObject, error) {
+	ref, ok := refs[scalar.String(branchPrefix+b.name)]
+	if !ok {
+		return core.Identifier{}, commitObject{}, fmt.Errorf("%w: branch %q", ErrRefNotFound, b.name)
+	}
+	commit, err := b.db.loadCommit(ctx, ref.ID)
+	return ref.ID, commit, err
+}
+
+func (db *DB) loadCommit(ctx *core.Context, id core.Identifier) (commitObject, error) {
+	commit, err := storage.Load[commitObject](ctx, db.storage, id)
+	if err != nil {
+		return commit, fmt.Errorf("load commit: %w", err)
+	}
+	return commit, nil
+}
+
+func (db *DB) ref(ctx context.Context, prefix string, name string) (core.Identifier, error) {
+	refs, err := db.loadRefs(db.context(ctx), db.currentRefsID())
+	if err != nil {
+		return core.Identifier{}, err
+	}
+	ref, ok := refs[scalar.String(prefix+name)]
+	if !ok {
+		return core.Identifier{}, fmt.Errorf("%w: %s%s", ErrRefNotFound, prefix, name)
+	}
+	return ref.ID, nil
+}
+
+func (db *DB) refNames(ctx context.Context, prefix string) ([]string, error) {
+	refs, err := db.loadRefs(db.context(ctx), db.currentRefsID())
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for key := range refs {
+		if name, ok := strings.CutPrefix(string(key), prefix); ok {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return names, nil
+}
+
+func (db *DB) deleteRef(ctx context.Context, prefix string, name string) error {
+	return db.updateRefs(ctx, func(c *core.Context, refs refs) error {
+		key := scalar.String(prefix + name)
+		if _, ok := refs[key]; !ok {
+			return fmt.Errorf("%w: %s%s", ErrRefNotFound, prefix, name)
+		}
+		delete(refs, key)
+		return nil
+	})
+}
+
+func (db *DB) currentRefsID() core.Identifier {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	return db.refsID
+}
+
+func (db *DB) loadRefs(ctx *core.Context, id core.Identifier) (refs, error) {
+	if id.Key == "" {
+		return make(refs), nil
+	}
+	ret, err := storage.Load[refs](ctx, db.storage, id)
+	if errors.Is(err, ErrTypeMismatch) {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidRoot, err)
+	} else if err != nil {
+		return nil, fmt.Errorf("load refs: %w", err)
+	}
+	return ret, nil
+}
+
+// updateRefs applies fn to a copy of the refs and publishes the result. The
+// storage is synced before the new refs are logged, since they are not
+// replayed from the WAL.
+func (db *DB) updateRefs(ctx context.Context, fn func(ctx *core.Context, refs refs) error) error {
+	db.refsMu.Lock()
+	defer db.refsMu.Unlock()
+
+	c := db.context(ctx)
+	current, err := db.loadRefs(c, db.currentRefsID())
+	if err != nil {
+		return err
+	}
+	updated := maps.Clone(current)
+	if err := fn(c, updated); err != nil {
+		return err
+	}
+	id, err := db.storage.Set(c, updated)
+	if err != nil {
+		return fmt.Errorf("store refs: %w", err)
+	}
+
+	var record []byte
+	if db.wal != nil {
+		if err := db.syncStorage(); err != nil {
+			return err
+		}
+		record, err = dshash.Marshal(walRecord{
+			Refs: id,
+		})
+		if err != nil {
+			return fmt.Errorf("encode wal record: %w", err)
+		}
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if record != nil {
+		if err := db.wal.Append(record); err != nil {
+			return fmt.Errorf("append wal: %w", err)
+		}
+	}
+	db.refsID = id
+	return nil
+}
+
+func (db *DB) context(ctx context.Context) *core.Context {
+	return &core.Context{
+		Context:         ctx,
+		PhysicalStorage: db.storage,
+	}
+}
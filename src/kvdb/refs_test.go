@@ -0,0 +1,218 @@
+package kvdb
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/ArborDB/arbordb/src/storage"
+)
+
+func branchGet(t *testing.T, branch *Branch, key string) string {
+	t.Helper()
+	var value string
+	if err := branch.View(context.Background(), func(tx *Tx) error {
+		var err error
+		value, err = tx.Get(key)
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return value
+}
+
+func TestBranches(t *testing.T) {
+	ctx := context.Background()
+	wal := new(memoryWAL)
+	store := storage.NewMemory()
+	db := reopen(t, store, wal)
+	put(t, db, "k", "main")
+
+	staging, err := db.CreateBranch(ctx, "staging", db.RootID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	production, err := db.CreateBranch(ctx, "production", db.RootID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.CreateBranch(ctx, "staging", db.RootID()); !errors.Is(err, ErrRefExists) {
+		t.Fatalf("expected exists, got %v", err)
+	}
+	if err := db.Branch("missing").Update(ctx, func(tx *Tx) error { return nil }); !errors.Is(err, ErrRefNotFound) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+
+	// branches are independent of each other and of the root of db
+	if err := staging.Update(ctx, func(tx *Tx) error {
+		return tx.Put("k", "staging")
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if got := branchGet(t, staging, "k"); got != "staging" {
+		t.Fatalf("got %q", got)
+	}
+	if got := branchGet(t, production, "k"); got != "main" {
+		t.Fatalf("got %q", got)
+	}
+	if err := db.View(ctx, func(tx *Tx) error {
+		if got, err := tx.Get("k"); err != nil || got != "main" {
+			t.Fatalf("got %q, %v", got, err)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// concurrent updates of a branch conflict
+	tx1, err := staging.Begin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx2, err := staging.Begin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tx1.Put("a", "1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx2.Put("b", "2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx1.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx2.Commit(); !errors.Is(err, ErrConflict) {
+		t.Fatalf("expected conflict, got %v", err)
+	}
+
+	// promotion
+	if err := production.FastForward(ctx, staging); err != nil {
+		t.Fatal(err)
+	}
+	stagingHead, err := staging.Head(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if head, err := production.Head(ctx); err != nil || head != stagingHead {
+		t.Fatalf("got %v, %v, want %v", head, err, stagingHead)
+	}
+	if err := production.Update(ctx, func(tx *Tx) error {
+		return tx.Put("hotfix", "1")
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := staging.FastForward(ctx, production); err != nil {
+		t.Fatal(err)
+	}
+	if err := staging.Update(ctx, func(tx *Tx) error {
+		return tx.Put("k", "next")
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := production.Update(ctx, func(tx *Tx) error {
+		return tx.Put("hotfix", "2")
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := production.FastForward(ctx, staging); !errors.Is(err, ErrNotFastForward) {
+		t.Fatalf("expected not fast-forward, got %v", err)
+	}
+
+	// compare and swap
+	productionHead, err := production.Head(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := production.CompareAndSwap(ctx, stagingHead, db.RootID()); !errors.Is(err, ErrConflict) {
+		t.Fatalf("expected conflict, got %v", err)
+	}
+	if err := production.CompareAndSwap(ctx, productionHead, stagingHead); err != nil {
+		t.Fatal(err)
+	}
+	if got := branchGet(t, production, "k"); got != "staging" {
+		t.Fatalf("got %q", got)
+	}
+
+	// tags
+	if err := db.CreateTag(ctx, "v1", productionHead); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.CreateTag(ctx, "v1", stagingHead); !errors.Is(err, ErrRefExists) {
+		t.Fatalf("expected exists, got %v", err)
+	}
+	if err := db.DeleteBranch(ctx, "staging"); err != nil {
+		t.Fatal(err)
+	}
+
+	// refs survive reopening
+	db = reopen(t, store, wal)
+	if names, err := db.Branches(ctx); err != nil || !slices.Equal(names, []string{"production"}) {
+		t.Fatalf("got %q, %v", names, err)
+	}
+	if names, err := db.Tags(ctx); err != nil || !slices.Equal(names, []string{"v1"}) {
+		t.Fatalf("got %q, %v", names, err)
+	}
+	if tag, err := db.Tag(ctx, "v1"); err != nil || tag != productionHead {
+		t.Fatalf("got %v, %v", tag, err)
+	}
+	if got := branchGet(t, db.Branch("production"), "k"); got != "staging" {
+		t.Fatalf("got %q", got)
+	}
+	if err := db.Checkpoint(); err != nil {
+		t.Fatal(err)
+	}
+	db = reopen(t, store, wal)
+	if names, err := db.Tags(ctx); err != nil || !slices.Equal(names, []string{"v1"}) {
+		t.Fatalf("got %q, %v", names, err)
+	}
+	tagged, err := db.Tag(ctx, "v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx, err := db.Snapshot(ctx, tagged)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := tx.Get("hotfix"); err != nil || got != "2" {
+		t.Fatalf("got %q, %v", got, err)
+	}
+	if _, err := db.Branch("production").Head(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// refs are restored without the WAL
+	restored := New(store, db.RootID())
+	if err := restored.RestoreRefs(ctx, db.RefsID()); err != nil {
+		t.Fatal(err)
+	}
+	if restored.RefsID() != db.RefsID() {
+		t.Fatalf("got %v, want %v", restored.RefsID(), db.RefsID())
+	}
+	if names, err := restored.Branches(ctx); err != nil || !slices.Equal(names, []string{"production"}) {
+		t.Fatalf("got %q, %v", names, err)
+	}
+
+	// a branch is promoted to the root of db
+	production = restored.Branch("production")
+	stale := restored.RootID()
+	put(t, restored, "k", "moved")
+	if err := production.Promote(ctx, stale); !errors.Is(err, ErrConflict) {
+		t.Fatalf("expected conflict, got %v", err)
+	}
+	if err := production.Promote(ctx, restored.RootID()); err != nil {
+		t.Fatal(err)
+	}
+	if head, err := production.Head(ctx); err != nil || head != restored.RootID() {
+		t.Fatalf("got %v, %v", head, err)
+	}
+	if err := restored.View(ctx, func(tx *Tx) error {
+		if got, err := tx.Get("k"); err != nil || got != "staging" {
+			t.Fatalf("got %q, %v", got, err)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,198 @@
+package kvdb
+
+import (
+	"context"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+
+	"github.com/ArborDB/arbordb/src/collection"
+	"github.com/ArborDB/arbordb/src/core"
+	"github.com/ArborDB/arbordb/src/storage"
+)
+
+// RegisterType registers the concrete type of value to be sent to peers by
+// Push, see storage.RegisterType. The types registered by storage and the
+// types of the roots are registered.
+func RegisterType(value core.Expression) {
+	storage.RegisterType(value)
+}
+
+func init() {
+	for _, value := range []core.Expression{
+		collection.SortedArray[IndexEntry]{},
+		refs{},
+		commitObject{},
+	} {
+		RegisterType(value)
+	}
+}
+
+// syncMessage is a message of the replication protocol. The serving peer
+// sends its root first, then answers each message of the pushing peer.
+type syncMessage struct {
+	// query: objects to check, answer: objects missing
+	IDs []core.Identifier
+	// objects to store, children before their parents
+	Objects []syncObject
+	// replace the root From by To
+	Advance bool
+	From    core.Identifier
+	To      core.Identifier
+	// answer: the root of the serving peer
+	Root     core.Identifier
+	Err      string
+	Conflict bool
+}
+
+type syncObject struct {
+	ID   core.Identifier
+	Expr core.Expression
+}
+
+// Push sends the objects reachable from the root of db that the peer on conn
+// lacks, then replaces the root of the peer with it. It returns ErrConflict
+// if the root of the peer changed meanwhile.
+//
+// Objects refer to others by Refs, see storage.References. The peer is
+// assumed to have all objects reachable from those it has, which holds since
+// children are sent before their parents.
+func (db *DB) Push(ctx context.Context, conn io.ReadWriter) error {
+	c := db.context(ctx)
+	enc := gob.NewEncoder(conn)
+	dec := gob.NewDecoder(conn)
+	exchange := func(msg syncMessage) (syncMessage, error) {
+		if err := ctx.Err(); err != nil {
+			return syncMessage{}, err
+		}
+		if err := enc.Encode(msg); err != nil {
+			return syncMessage{}, fmt.Errorf("send: %w", err)
+		}
+		var reply syncMessage
+		if err := dec.Decode(&reply); err != nil {
+			return syncMessage{}, fmt.Errorf("receive: %w", err)
+		}
+		if reply.Conflict {
+			return reply, fmt.Errorf("%w: %s", ErrConflict, reply.Err)
+		}
+		if reply.Err != "" {
+			return reply, fmt.Errorf("peer: %s", reply.Err)
+		}
+		return reply, nil
+	}
+
+	var hello syncMessage
+	if err := dec.Decode(&hello); err != nil {
+		return fmt.Errorf("receive: %w", err)
+	}
+	rootID := db.RootID()
+
+	// levels of missing objects, from the root
+	var levels [][]syncObject
+	seen := make(map[core.Identifier]bool)
+	frontier := []core.Identifier{rootID}
+	for len(frontier) > 0 {
+		var query []core.Identifier
+		for _, id := range frontier {
+			if id.Key != "" && !seen[id] {
+				seen[id] = true
+				query = append(query, id)
+			}
+		}
+		if len(query) == 0 {
+			break
+		}
+		reply, err := exchange(syncMessage{IDs: query})
+		if err != nil {
+			return err
+		}
+		exprs, err := storage.Batched(db.storage).GetMany(c, reply.IDs)
+		if err != nil {
+			return fmt.Errorf("load: %w", err)
+		}
+		var level []syncObject
+		frontier = nil
+		for i, id := range reply.IDs {
+			level = append(level, syncObject{ID: id, Expr: exprs[i]})
+			frontier = append(frontier, storage.References(exprs[i])...)
+		}
+		levels = append(levels, level)
+	}
+
+	for i := len(levels) - 1; i >= 0; i-- {
+		if len(levels[i]) == 0 {
+			continue
+		}
+		if _, err := exchange(syncMessage{Objects: levels[i]}); err != nil {
+			return err
+		}
+	}
+	_, err := exchange(syncMessage{
+		Advance: true,
+		From:    hello.Root,
+		To:      rootID,
+	})
+	return err
+}
+
+// Serve answers the pushes of the peer on conn until conn is closed.
+func (db *DB) Serve(ctx context.Context, conn io.ReadWriter) error {
+	c := db.context(ctx)
+	batch := storage.Batched(db.storage)
+	enc := gob.NewEncoder(conn)
+	dec := gob.NewDecoder(conn)
+
+	if err := enc.Encode(syncMessage{Root: db.RootID()}); err != nil {
+		return fmt.Errorf("send: %w", err)
+	}
+	for {
+		var msg syncMessage
+		if err := dec.Decode(&msg); err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrClosedPipe) {
+				return nil
+			}
+			return fmt.Errorf("receive: %w", err)
+		}
+
+		var reply syncMessage
+		err := func() error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			for existence, err := range batch.HasMany(c, slices.Values(msg.IDs)) {
+				if err != nil {
+					return fmt.Errorf("check %v: %w", existence.ID, err)
+				}
+				if !existence.Stored {
+					reply.IDs = append(reply.IDs, existence.ID)
+				}
+			}
+			exprs := make([]core.Expression, 0, len(msg.Objects))
+			for _, object := range msg.Objects {
+				exprs = append(exprs, object.Expr)
+			}
+			ids, err := batch.SetMany(c, exprs)
+			if err != nil {
+				return fmt.Errorf("store: %w", err)
+			}
+			for i, object := range msg.Objects {
+				if ids[i] != object.ID {
+					return fmt.Errorf("object %v hashes to %v", object.ID, ids[i])
+				}
+			}
+			if msg.Advance {
+				return db.replaceRoot(msg.From, msg.To)
+			}
+			return nil
+		}()
+		if err != nil {
+			reply.Err = err.Error()
+			reply.Conflict = errors.Is(err, ErrConflict)
+		}
+		if err := enc.Encode(reply); err != nil {
+			return fmt.Errorf("send: %w", err)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package kvdb
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ArborDB/arbordb/src/core"
+	"github.com/ArborDB/arbordb/src/scalar"
+	"github.com/ArborDB/arbordb/src/storage"
+)
+
+// countingStorage counts the stored expressions
+type countingStorage struct {
+	*storage.Memory
+	sets int
+}
+
+func (c *countingStorage) Set(ctx *core.Context, expr core.Expression) (core.Identifier, error) {
+	c.sets++
+	return c.Memory.Set(ctx, expr)
+}
+
+func push(t *testing.T, from *DB, to *DB) error {
+	t.Helper()
+	client, server := net.Pipe()
+	served := make(chan error, 1)
+	go func() {
+		served <- to.Serve(context.Background(), server)
+	}()
+	err := from.Push(context.Background(), client)
+	client.Close()
+	if err := <-served; err != nil {
+		t.Fatal(err)
+	}
+	return err
+}
+
+func TestReplication(t *testing.T) {
+	ctx := context.Background()
+	primary := New(storage.NewMemory(), core.Identifier{})
+	put(t, primary, "a", "1", "b", "2")
+	if err := primary.Update(ctx, func(tx *Tx) error {
+		bucket, err := tx.CreateBucket("typed")
+		if err != nil {
+			return err
+		}
+		return bucket.PutValue("t", scalar.NewTimestamp(time.Unix(1, 0)))
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	store := &countingStorage{Memory: storage.NewMemory()}
+	wal := new(memoryWAL)
+	replica := reopen(t, store, wal)
+	if err := push(t, primary, replica); err != nil {
+		t.Fatal(err)
+	}
+	if replica.RootID() != primary.RootID() {
+		t.Fatalf("got root %v, want %v", replica.RootID(), primary.RootID())
+	}
+	want := dumpDB(t, primary)
+	if got := dumpDB(t, replica); !maps.Equal(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	// pushing again sends nothing
+	store.sets = 0
+	if err := push(t, primary, replica); err != nil {
+		t.Fatal(err)
+	}
+	if store.sets != 0 {
+		t.Fatalf("stored %d objects", store.sets)
+	}
+
+	// the replica root survives reopening
+	put(t, primary, "a", "10")
+	if err := push(t, primary, replica); err != nil {
+		t.Fatal(err)
+	}
+	replica = reopen(t, store, wal)
+	want = dumpDB(t, primary)
+	if got := dumpDB(t, replica); !maps.Equal(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	// transactions on the replica conflict with pushes
+	tx, err := replica.Begin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Put("c", "3"); err != nil {
+		t.Fatal(err)
+	}
+	put(t, primary, "b", "20")
+	if err := push(t, primary, replica); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); !errors.Is(err, ErrConflict) {
+		t.Fatalf("expected conflict, got %v", err)
+	}
+}
@@ -0,0 +1,181 @@
+package kvdb
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"iter"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// WAL is a write-ahead log of opaque records.
+type WAL interface {
+	// Append durably appends a record to the log.
+	Append(record []byte) error
+	// Records iterates the records of the log in order.
+	Records() iter.Seq2[[]byte, error]
+	// Reset atomically replaces the log with a single record.
+	Reset(record []byte) error
+}
+
+// FileWAL is a WAL in a file. Each record is framed by its length and CRC-32C
+// checksum as little-endian uint32s. A torn record at the end of the file,
+// left by a crash during Append, is discarded when the file is opened.
+type FileWAL struct {
+	mu   sync.Mutex
+	path string
+	file *os.File
+}
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+const walHeaderSize = 8
+
+func OpenFileWAL(path string) (*FileWAL, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	w := &FileWAL{
+		path: path,
+		file: file,
+	}
+
+	// discard the torn tail
+	var size int64
+	for record, err := range w.Records() {
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		size += walHeaderSize + int64(len(record))
+	}
+	if err := file.Truncate(size); err != nil {
+		file.Close()
+		return nil, err
+	}
+	if _, err := file.Seek(size, io.SeekStart); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return w, nil
+}
+
+var _ WAL = (*FileWAL)(nil)
+
+func (w *FileWAL) Append(record []byte) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, err := w.file.Write(frame(record)); err != nil {
+		return err
+	}
+	failpoint("wal:written")
+	return w.file.Sync()
+}
+
+// Records iterates the valid records from the start of the file.
+func (w *FileWAL) Records() iter.Seq2[[]byte, error] {
+	return func(yield func([]byte, error) bool) {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		r := bufio.NewReader(io.NewSectionReader(w.file, 0, 1<<62))
+		header := make([]byte, walHeaderSize)
+		for {
+			if _, err := io.ReadFull(r, header); err != nil {
+				if err == io.EOF || err == io.ErrUnexpectedEOF {
+					return
+				}
+				yield(nil, err)
+				return
+			}
+			length := binary.LittleEndian.Uint32(header[0:4])
+			checksum := binary.LittleEndian.Uint32(header[4:8])
+			// do not trust the length of a torn record
+			var buf bytes.Buffer
+			n, err := io.CopyN(&buf, r, int64(length))
+			if err != nil && !errors.Is(err, io.EOF) {
+				yield(nil, err)
+				return
+			}
+			if n < int64(length) || crc32.Checksum(buf.Bytes(), crcTable) != checksum {
+				return
+			}
+			if !yield(buf.Bytes(), nil) {
+				return
+			}
+		}
+	}
+}
+
+func (w *FileWAL) Reset(record []byte) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	tmpPath := w.path + ".tmp"
+	tmp, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(frame(record)); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	failpoint("wal:reset-written")
+	if err := os.Rename(tmpPath, w.path); err != nil {
+		return err
+	}
+	if err := syncDir(filepath.Dir(w.path)); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(w.path, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Seek(0, io.SeekEnd); err != nil {
+		file.Close()
+		return err
+	}
+	w.file.Close()
+	w.file = file
+	return nil
+}
+
+func (w *FileWAL) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.file.Close()
+}
+
+func frame(record []byte) []byte {
+	buf := make([]byte, walHeaderSize, walHeaderSize+len(record))
+	binary.LittleEndian.PutUint32(buf[0:4], uint32(len(record)))
+	binary.LittleEndian.PutUint32(buf[4:8], crc32.Checksum(record, crcTable))
+	return append(buf, record...)
+}
+
+func syncDir(path string) error {
+	dir, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+	if err := dir.Sync(); err != nil {
+		return fmt.Errorf("sync %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,432 @@
+package kvdb
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"maps"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/ArborDB/arbordb/src/core"
+	"github.com/ArborDB/arbordb/src/storage"
+)
+
+// walWorkload runs transactions against db, calling done after each commit
+func walWorkload(db *DB, done func()) error {
+	ctx := context.Background()
+	steps := []func(tx *Tx) error{
+		func(tx *Tx) error {
+			a, err := tx.CreateBucket("a")
+			if err != nil {
+				return err
+			}
+			if err := a.Put("k1", "v1"); err != nil {
+				return err
+			}
+			return tx.Put("x", "1")
+		},
+		func(tx *Tx) error {
+			a, err := tx.Bucket("a")
+			if err != nil {
+				return err
+			}
+			if err := a.Put("k2", "v2"); err != nil {
+				return err
+			}
+			return a.Delete("k1")
+		},
+		nil, // checkpoint
+		func(tx *Tx) error {
+			if err := tx.DeleteBucket("a"); err != nil {
+				return err
+			}
+			b, err := tx.CreateBucket("b")
+			if err != nil {
+				return err
+			}
+			return b.Put("k", "v")
+		},
+		func(tx *Tx) error {
+			return tx.Put("x", "2")
+		},
+	}
+	for _, step := range steps {
+		if step == nil {
+			if err := db.Checkpoint(); err != nil {
+				return err
+			}
+			continue
+		}
+		tx, err := db.Begin(ctx)
+		if err != nil {
+			return err
+		}
+		if err := step(tx); err != nil {
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+		done()
+	}
+	return nil
+}
+
+// dumpDB returns all keys of all buckets as "bucket/key"
+func dumpDB(t *testing.T, db *DB) map[string]string {
+	t.Helper()
+	tx, err := db.Begin(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	names, err := tx.Buckets()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ret := make(map[string]string)
+	for _, name := range names {
+		bucket, err := tx.Bucket(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tx.mu.Lock()
+		for kv, err := range bucket.iterDict() {
+			if err != nil {
+				t.Fatal(err)
+			}
+			ret[name+"/"+string(kv.Key)] = kv.Value.String()
+		}
+		tx.mu.Unlock()
+	}
+	return ret
+}
+
+func openWALDB(t *testing.T, store core.PhysicalStorage, path string) (*DB, *FileWAL) {
+	t.Helper()
+	wal, err := OpenFileWAL(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := Open(context.Background(), store, wal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db, wal
+}
+
+func setFailpoint(t *testing.T, fn func(name string)) {
+	prev := failpoint
+	failpoint = fn
+	t.Cleanup(func() {
+		failpoint = prev
+	})
+}
+
+type crash struct{}
+
+// bufferedStorage keeps the stored expressions in memory until synced, and
+// drops them on crash
+type bufferedStorage struct {
+	mu      sync.Mutex
+	durable *storage.Memory
+	pending map[core.Identifier]core.Expression
+}
+
+var _ Syncer = (*bufferedStorage)(nil)
+
+func newBufferedStorage() *bufferedStorage {
+	return &bufferedStorage{
+		durable: storage.NewMemory(),
+		pending: make(map[core.Identifier]core.Expression),
+	}
+}
+
+func (b *bufferedStorage) Set(ctx *core.Context, expr core.Expression) (core.Identifier, error) {
+	id, err := storage.Identify(expr)
+	if err != nil {
+		return id, err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.pending[id] = expr
+	return id, nil
+}
+
+func (b *bufferedStorage) Get(ctx *core.Context, id core.Identifier, target any) error {
+	b.mu.Lock()
+	expr, ok := b.pending[id]
+	b.mu.Unlock()
+	if ok {
+		reflect.ValueOf(target).Elem().Set(reflect.ValueOf(expr))
+		return nil
+	}
+	return b.durable.Get(ctx, id, target)
+}
+
+func (b *bufferedStorage) Has(ctx *core.Context, id core.Identifier) (bool, error) {
+	b.mu.Lock()
+	_, ok := b.pending[id]
+	b.mu.Unlock()
+	if ok {
+		return true, nil
+	}
+	return b.durable.Has(ctx, id)
+}
+
+func (b *bufferedStorage) Delete(ctx *core.Context, id core.Identifier) error {
+	b.mu.Lock()
+	delete(b.pending, id)
+	b.mu.Unlock()
+	return b.durable.Delete(ctx, id)
+}
+
+func (b *bufferedStorage) Iterate(ctx *core.Context) iter.Seq2[core.Identifier, error] {
+	return b.durable.Iterate(ctx)
+}
+
+func (b *bufferedStorage) Sync() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for id, expr := range b.pending {
+		if err := b.durable.SetAs(nil, id, expr); err != nil {
+			return err
+		}
+	}
+	clear(b.pending)
+	return nil
+}
+
+func (b *bufferedStorage) crash() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	clear(b.pending)
+}
+
+func TestWALRecovery(t *testing.T) {
+	// reference run recording the failpoints and the state after each commit
+	var steps []string
+	setFailpoint(t, func(name string) {
+		steps = append(steps, name)
+	})
+	path := filepath.Join(t.TempDir(), "wal")
+	store := storage.NewMemory()
+	db, wal := openWALDB(t, store, path)
+	states := []map[string]string{dumpDB(t, db)}
+	if err := walWorkload(db, func() {
+		states = append(states, dumpDB(t, db))
+	}); err != nil {
+		t.Fatal(err)
+	}
+	wal.Close()
+	setFailpoint(t, func(string) {})
+	if len(steps) == 0 {
+		t.Fatal("no failpoints")
+	}
+
+	// reopening recovers the last state
+	db, wal = openWALDB(t, store, path)
+	if got := dumpDB(t, db); !maps.Equal(got, states[len(states)-1]) {
+		t.Fatalf("got %v, want %v", got, states[len(states)-1])
+	}
+	wal.Close()
+
+	// crash at every step
+	for i, step := range steps {
+		t.Run(fmt.Sprintf("%d-%s", i, step), func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "wal")
+			store := newBufferedStorage()
+			db, wal := openWALDB(t, store, path)
+
+			n := 0
+			setFailpoint(t, func(name string) {
+				if n == i {
+					panic(crash{})
+				}
+				n++
+			})
+			committed := 0
+			func() {
+				defer func() {
+					if p := recover(); p != nil {
+						if _, ok := p.(crash); !ok {
+							panic(p)
+						}
+					}
+				}()
+				if err := walWorkload(db, func() {
+					committed++
+				}); err != nil {
+					t.Fatal(err)
+				}
+				t.Fatal("expected crash")
+			}()
+			wal.Close()
+			store.crash()
+			setFailpoint(t, func(string) {})
+
+			// the crashed transaction is recovered iff it was logged
+			want := states[committed]
+			if step == "commit:logged" || step == "wal:written" {
+				want = states[committed+1]
+			}
+			db, wal = openWALDB(t, store, path)
+			if got := dumpDB(t, db); !maps.Equal(got, want) {
+				t.Fatalf("got %v, want %v", got, want)
+			}
+
+			// the recovered database accepts commits
+			tx, err := db.Begin(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := tx.Put("after", "crash"); err != nil {
+				t.Fatal(err)
+			}
+			if err := tx.Commit(); err != nil {
+				t.Fatal(err)
+			}
+			wal.Close()
+			store.crash()
+			db, wal = openWALDB(t, store, path)
+			defer wal.Close()
+			if v, ok := dumpDB(t, db)["/after"]; !ok || v != "crash" {
+				t.Fatalf("commit after recovery lost")
+			}
+		})
+	}
+}
+
+func TestWALRecoveryIndex(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "wal")
+	store := storage.NewMemory()
+	wal, err := OpenFileWAL(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := Open(ctx, store, wal, cityIndex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	users, err := tx.CreateBucket("users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := users.Put("1", "alice@paris"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	tx, err = db.Begin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	users, err = tx.Bucket("users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := users.Put("2", "bob@berlin"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	rootID := db.RootID()
+	wal.Close()
+
+	// replaying with the index recovers the same root
+	wal, err = OpenFileWAL(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err = Open(ctx, store, wal, cityIndex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if db.RootID() != rootID {
+		t.Fatalf("got root %v, want %v", db.RootID(), rootID)
+	}
+	tx, err = db.Begin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := scanIndex(t, tx, "city", KeyRange{}); !slices.Equal(got, []string{"berlin/2", "paris/1"}) {
+		t.Fatalf("got %q", got)
+	}
+	tx.Rollback()
+	wal.Close()
+
+	// replaying without it would drop the index
+	wal, err = OpenFileWAL(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wal.Close()
+	if _, err := Open(ctx, store, wal); err == nil {
+		t.Fatal("expected replay without the index to fail")
+	}
+}
+
+func TestWALTornTail(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wal")
+	store := storage.NewMemory()
+	db, wal := openWALDB(t, store, path)
+	var states []map[string]string
+	var sizes []int64
+	if err := walWorkload(db, func() {
+		states = append(states, dumpDB(t, db))
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sizes = append(sizes, info.Size())
+	}); err != nil {
+		t.Fatal(err)
+	}
+	wal.Close()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// every prefix of the last record is discarded
+	last := len(states) - 1
+	for size := sizes[last-1]; size < sizes[last]; size++ {
+		tornPath := filepath.Join(t.TempDir(), "wal")
+		if err := os.WriteFile(tornPath, data[:size], 0o644); err != nil {
+			t.Fatal(err)
+		}
+		db, wal := openWALDB(t, store, tornPath)
+		if got := dumpDB(t, db); !maps.Equal(got, states[last-1]) {
+			t.Fatalf("size %d: got %v, want %v", size, got, states[last-1])
+		}
+
+		// appends follow the last valid record
+		tx, err := db.Begin(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := tx.Put("x", "3"); err != nil {
+			t.Fatal(err)
+		}
+		if err := tx.Commit(); err != nil {
+			t.Fatal(err)
+		}
+		wal.Close()
+		db, wal = openWALDB(t, store, tornPath)
+		if got := dumpDB(t, db)["/x"]; got != "3" {
+			t.Fatalf("size %d: got %v", size, got)
+		}
+		wal.Close()
+	}
+}
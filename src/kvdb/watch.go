@@ -0,0 +1,149 @@
+package kvdb
+
+import (
+	"context"
+	"iter"
+	"slices"
+	"strings"
+
+	"github.com/ArborDB/arbordb/src/collection"
+	"github.com/ArborDB/arbordb/src/core"
+	"github.com/ArborDB/arbordb/src/scalar"
+)
+
+// Change is a change of the value of a key. Old is nil for created keys and
+// New is nil for deleted keys.
+type Change struct {
+	Bucket string
+	Key    string
+	Old    core.Expression
+	New    core.Expression
+	// the root published with the change
+	RootID core.Identifier
+}
+
+// Watch returns the changes of the keys with prefix in a bucket, committed
+// after the root from, or after the current root if from is zero. The
+// iteration waits for new commits until it is stopped or ctx is done.
+//
+// Changes are computed by diffing consecutive roots while the watcher keeps
+// up. A watcher falling behind the retained root history, or resuming from a
+// root not in it, gets the changes between its root and the current root
+// instead, so commits are never blocked by slow watchers.
+func (db *DB) Watch(ctx context.Context, bucket string, prefix string, from core.Identifier) iter.Seq2[Change, error] {
+	if from == (core.Identifier{}) {
+		from = db.RootID()
+	}
+	return func(yield func(Change, error) bool) {
+		c := &core.Context{
+			Context:         ctx,
+			PhysicalStorage: db.storage,
+		}
+
+		last := from
+		for {
+			db.mu.RLock()
+			roots := db.rootsAfter(last)
+			published := db.published
+			db.mu.RUnlock()
+
+			for _, root := range roots {
+				changes, err := db.diffRoots(c, last, root, bucket, prefix)
+				if err != nil {
+					yield(Change{}, err)
+					return
+				}
+				for _, change := range changes {
+					if !yield(change, nil) {
+						return
+					}
+				}
+				last = root
+			}
+
+			select {
+			case <-published:
+			case <-ctx.Done():
+				yield(Change{}, ctx.Err())
+				return
+			}
+		}
+	}
+}
+
+// rootsAfter returns the roots published after root. db.mu must be held.
+func (db *DB) rootsAfter(root core.Identifier) []core.Identifier {
+	if root == db.rootID {
+		return nil
+	}
+	for i, id := range slices.Backward(db.roots) {
+		if id == root {
+			return slices.Clone(db.roots[i+1:])
+		}
+	}
+	// not retained, coalesce
+	return []core.Identifier{db.rootID}
+}
+
+// Diff returns the changes of the keys of a bucket between two roots, sorted
+// by key.
+func (db *DB) Diff(ctx context.Context, bucket string, from core.Identifier, to core.Identifier) ([]Change, error) {
+	return db.diffRoots(&core.Context{
+		Context:         ctx,
+		PhysicalStorage: db.storage,
+	}, from, to, bucket, "")
+}
+
+// diffRoots returns the changes of the keys with prefix in a bucket between
+// two roots, sorted by key.
+func (db *DB) diffRoots(ctx *core.Context, oldID core.Identifier, newID core.Identifier, bucket string, prefix string) ([]Change, error) {
+	oldBucket, err := db.loadBucket(ctx, oldID, bucket)
+	if err != nil {
+		return nil, err
+	}
+	newBucket, err := db.loadBucket(ctx, newID, bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []Change
+	for change, err := range collection.DiffDict(ctx, oldBucket, newBucket) {
+		if err != nil {
+			return nil, err
+		}
+		if !strings.HasPrefix(string(change.Key), prefix) {
+			continue
+		}
+		changes = append(changes, Change{
+			Bucket: bucket,
+			Key:    string(change.Key),
+			Old:    change.Old,
+			New:    change.New,
+			RootID: newID,
+		})
+	}
+	slices.SortFunc(changes, func(a, b Change) int {
+		return strings.Compare(a.Key, b.Key)
+	})
+	return changes, nil
+}
+
+// loadBucket returns a bucket of a root, which is empty if it does not exist.
+func (db *DB) loadBucket(ctx *core.Context, rootID core.Identifier, name string) (collection.Dict[scalar.String, core.Expression], error) {
+	root, err := db.loadRoot(ctx, rootID)
+	if err != nil {
+		return nil, err
+	}
+	exists, err := root.Exists(ctx, scalar.String(name))
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return make(collection.Map[scalar.String, core.Expression]), nil
+	}
+	expr, err := root.Get(ctx, scalar.String(name))
+	if err != nil {
+		return nil, err
+	}
+	return asBucket(name, expr)
+}
@@ -0,0 +1,153 @@
+package kvdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"slices"
+	"testing"
+
+	"github.com/ArborDB/arbordb/src/core"
+	"github.com/ArborDB/arbordb/src/storage"
+)
+
+func formatChange(change Change) string {
+	return fmt.Sprintf("%s: %v -> %v", change.Key, change.Old, change.New)
+}
+
+func nextChange(t *testing.T, next func() (Change, error, bool)) Change {
+	t.Helper()
+	change, err, ok := next()
+	if !ok {
+		t.Fatal("watch stopped")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return change
+}
+
+func put(t *testing.T, db *DB, kvs ...string) {
+	t.Helper()
+	if err := db.Update(context.Background(), func(tx *Tx) error {
+		for i := 0; i < len(kvs); i += 2 {
+			var err error
+			if kvs[i+1] == "" {
+				err = tx.Delete(kvs[i])
+			} else {
+				err = tx.Put(kvs[i], kvs[i+1])
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	wal := new(memoryWAL)
+	store := storage.NewMemory()
+	db := reopen(t, store, wal)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	next, stop := iter.Pull2(db.Watch(ctx, DefaultBucket, "user/", core.Identifier{}))
+	defer stop()
+
+	put(t, db, "user/1", "a", "other", "x")
+	change := nextChange(t, next)
+	if got := formatChange(change); got != "user/1: <nil> -> a" {
+		t.Fatalf("got %s", got)
+	}
+	if change.RootID != db.RootID() {
+		t.Fatalf("got root %v, want %v", change.RootID, db.RootID())
+	}
+	resumeFrom := change.RootID
+
+	// unchanged values are skipped, changes of a commit are sorted by key
+	put(t, db, "user/1", "a")
+	put(t, db, "user/3", "c", "user/2", "b", "user/1", "")
+	for _, want := range []string{
+		"user/1: a -> <nil>",
+		"user/2: <nil> -> b",
+		"user/3: <nil> -> c",
+	} {
+		if got := formatChange(nextChange(t, next)); got != want {
+			t.Fatalf("got %s, want %s", got, want)
+		}
+	}
+
+	// commits do not wait for watchers
+	for i := range 10 {
+		put(t, db, "user/2", fmt.Sprint(i))
+	}
+	for i := range 10 {
+		want := fmt.Sprintf("user/2: %d -> %d", i-1, i)
+		if i == 0 {
+			want = "user/2: b -> 0"
+		}
+		if got := formatChange(nextChange(t, next)); got != want {
+			t.Fatalf("got %s, want %s", got, want)
+		}
+	}
+
+	// resuming from a retained root
+	resumed, stopResumed := iter.Pull2(db.Watch(ctx, DefaultBucket, "user/", resumeFrom))
+	defer stopResumed()
+	for _, want := range []string{
+		"user/1: a -> <nil>",
+		"user/2: <nil> -> b",
+		"user/3: <nil> -> c",
+		"user/2: b -> 0",
+	} {
+		if got := formatChange(nextChange(t, resumed)); got != want {
+			t.Fatalf("got %s, want %s", got, want)
+		}
+	}
+
+	// resuming from a root not retained coalesces the changes
+	db = reopen(t, store, wal)
+	coalesced, stopCoalesced := iter.Pull2(db.Watch(ctx, DefaultBucket, "user/", resumeFrom))
+	defer stopCoalesced()
+	for _, want := range []string{
+		"user/1: a -> <nil>",
+		"user/2: <nil> -> 9",
+		"user/3: <nil> -> c",
+	} {
+		change := nextChange(t, coalesced)
+		if got := formatChange(change); got != want {
+			t.Fatalf("got %s, want %s", got, want)
+		}
+		if change.RootID != db.RootID() {
+			t.Fatalf("got root %v, want %v", change.RootID, db.RootID())
+		}
+	}
+
+	cancel()
+	if _, err, _ := coalesced(); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected canceled, got %v", err)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	db := New(storage.NewMemory(), core.Identifier{})
+	put(t, db, "a", "1", "b", "2")
+	from := db.RootID()
+	put(t, db, "a", "10", "b", "", "c", "3")
+	changes, err := db.Diff(context.Background(), DefaultBucket, from, db.RootID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, change := range changes {
+		got = append(got, formatChange(change))
+	}
+	want := []string{"a: 1 -> 10", "b: 2 -> <nil>", "c: <nil> -> 3"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
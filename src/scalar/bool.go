@@ -0,0 +1,49 @@
+package scalar
+
+import (
+	"strconv"
+
+	"github.com/ArborDB/arbordb/src/core"
+)
+
+type Bool bool
+
+var _ core.Expression = Bool(false)
+
+func (b Bool) String() string {
+	return strconv.FormatBool(bool(b))
+}
+
+var _ core.LogicalIdentifiable = Bool(false)
+
+func (b Bool) LogicalID(ctx *core.Context) (core.Identifier, error) {
+	return core.Identifier{
+		Kind: "bool",
+		Key:  strconv.FormatBool(bool(b)),
+	}, nil
+}
+
+var _ core.PhysicalIdentifiable = Bool(false)
+
+func (b Bool) PhysicalID(ctx *core.Context) (core.Identifier, error) {
+	return b.LogicalID(ctx)
+}
+
+var _ core.CanonicalIdentifiable = Bool(false)
+
+func (b Bool) CanonicalID(ctx *core.Context) (core.Identifier, error) {
+	return b.LogicalID(ctx)
+}
+
+var _ core.Ordered[Bool] = Bool(false)
+
+// Compare orders false before true.
+func (b Bool) Compare(to Bool) int {
+	if b == to {
+		return 0
+	}
+	if b {
+		return 1
+	}
+	return -1
+}
@@ -0,0 +1,45 @@
+package scalar
+
+import (
+	"encoding/hex"
+	"strings"
+
+	"github.com/ArborDB/arbordb/src/core"
+)
+
+// Bytes is an immutable byte string. It is a string type so that it can be
+// used as a Dict key.
+type Bytes string
+
+var _ core.Expression = Bytes("")
+
+func (b Bytes) String() string {
+	return hex.EncodeToString([]byte(b))
+}
+
+var _ core.LogicalIdentifiable = Bytes("")
+
+func (b Bytes) LogicalID(ctx *core.Context) (core.Identifier, error) {
+	return core.Identifier{
+		Kind: "bytes",
+		Key:  hex.EncodeToString([]byte(b)),
+	}, nil
+}
+
+var _ core.PhysicalIdentifiable = Bytes("")
+
+func (b Bytes) PhysicalID(ctx *core.Context) (core.Identifier, error) {
+	return b.LogicalID(ctx)
+}
+
+var _ core.CanonicalIdentifiable = Bytes("")
+
+func (b Bytes) CanonicalID(ctx *core.Context) (core.Identifier, error) {
+	return b.LogicalID(ctx)
+}
+
+var _ core.Ordered[Bytes] = Bytes("")
+
+func (b Bytes) Compare(to Bytes) int {
+	return strings.Compare(string(b), string(to))
+}
@@ -0,0 +1,156 @@
+package scalar
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/ArborDB/arbordb/src/core"
+)
+
+// Decimal is the exact decimal number Coefficient * 10^Exponent. Use
+// NewDecimal or ParseDecimal to obtain the normalized form, in which equal
+// numbers are equal values.
+type Decimal struct {
+	Coefficient int64
+	Exponent    int32
+}
+
+// NewDecimal returns coefficient * 10^exponent in normalized form, without
+// trailing zeros in the coefficient. Trailing zeros are kept if the exponent
+// would exceed math.MaxInt32.
+func NewDecimal(coefficient int64, exponent int32) Decimal {
+	if coefficient == 0 {
+		return Decimal{}
+	}
+	for coefficient%10 == 0 && exponent < math.MaxInt32 {
+		coefficient /= 10
+		exponent++
+	}
+	return Decimal{
+		Coefficient: coefficient,
+		Exponent:    exponent,
+	}
+}
+
+// ParseDecimal parses numbers like "-12.340" and "1.5e-3".
+func ParseDecimal(s string) (Decimal, error) {
+	mantissa, exponentPart, hasExponent := strings.Cut(strings.ToLower(s), "e")
+	var exponent int64
+	if hasExponent {
+		var err error
+		exponent, err = strconv.ParseInt(exponentPart, 10, 32)
+		if err != nil {
+			return Decimal{}, fmt.Errorf("invalid decimal: %q", s)
+		}
+	}
+	intPart, fracPart, _ := strings.Cut(mantissa, ".")
+	digits := intPart + fracPart
+	if digits == "" || digits == "-" || digits == "+" || strings.ContainsAny(fracPart, "+-") {
+		return Decimal{}, fmt.Errorf("invalid decimal: %q", s)
+	}
+	coefficient, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil {
+		return Decimal{}, fmt.Errorf("invalid decimal: %q: %w", s, err)
+	}
+	exponent -= int64(len(fracPart))
+	if int64(int32(exponent)) != exponent {
+		return Decimal{}, fmt.Errorf("decimal exponent out of range: %q", s)
+	}
+	return NewDecimal(coefficient, int32(exponent)), nil
+}
+
+func (d Decimal) Rat() *big.Rat {
+	r := new(big.Rat).SetInt64(d.Coefficient)
+	exponent := int64(d.Exponent)
+	if exponent >= 0 {
+		return r.Mul(r, new(big.Rat).SetInt(pow10(exponent)))
+	}
+	return r.Quo(r, new(big.Rat).SetInt(pow10(-exponent)))
+}
+
+func pow10(n int64) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(n), nil)
+}
+
+// magnitude returns the position of the most significant digit, so that
+// 10^(magnitude-1) <= |d| < 10^magnitude for non-zero d.
+func (d Decimal) magnitude() int64 {
+	abs := uint64(d.Coefficient)
+	if d.Coefficient < 0 {
+		abs = -abs
+	}
+	return int64(len(strconv.FormatUint(abs, 10))) + int64(d.Exponent)
+}
+
+var _ core.Expression = Decimal{}
+
+func (d Decimal) String() string {
+	d = NewDecimal(d.Coefficient, d.Exponent)
+	digits := strconv.FormatInt(d.Coefficient, 10)
+	sign := ""
+	if d.Coefficient < 0 {
+		sign, digits = "-", digits[1:]
+	}
+	// the exponent is compared as is, negating MinInt32 overflows
+	switch exponent := int(d.Exponent); {
+	case exponent >= 0 && exponent <= 20:
+		return sign + digits + strings.Repeat("0", exponent)
+	case exponent < 0 && exponent > -20:
+		point := len(digits) + exponent
+		if point <= 0 {
+			return sign + "0." + strings.Repeat("0", -point) + digits
+		}
+		return sign + digits[:point] + "." + digits[point:]
+	}
+	return sign + digits + "e" + strconv.Itoa(int(d.Exponent))
+}
+
+var _ core.LogicalIdentifiable = Decimal{}
+
+func (d Decimal) LogicalID(ctx *core.Context) (core.Identifier, error) {
+	return core.Identifier{
+		Kind: "decimal",
+		Key:  d.String(),
+	}, nil
+}
+
+var _ core.PhysicalIdentifiable = Decimal{}
+
+func (d Decimal) PhysicalID(ctx *core.Context) (core.Identifier, error) {
+	return d.LogicalID(ctx)
+}
+
+var _ core.CanonicalIdentifiable = Decimal{}
+
+func (d Decimal) CanonicalID(ctx *core.Context) (core.Identifier, error) {
+	return d.LogicalID(ctx)
+}
+
+var _ core.Ordered[Decimal] = Decimal{}
+
+func (d Decimal) Compare(to Decimal) int {
+	if d.Exponent == to.Exponent {
+		return cmp.Compare(d.Coefficient, to.Coefficient)
+	}
+	sign := cmp.Compare(d.Coefficient, 0)
+	if c := cmp.Compare(sign, cmp.Compare(to.Coefficient, 0)); c != 0 || sign == 0 {
+		return c
+	}
+	// same sign, compare the absolute values by magnitude first
+	if c := cmp.Compare(d.magnitude(), to.magnitude()); c != 0 {
+		return sign * c
+	}
+	// the exponents differ by less than 19, the digits of an int64
+	a := big.NewInt(d.Coefficient)
+	b := big.NewInt(to.Coefficient)
+	if d.Exponent > to.Exponent {
+		a.Mul(a, pow10(int64(d.Exponent)-int64(to.Exponent)))
+	} else {
+		b.Mul(b, pow10(int64(to.Exponent)-int64(d.Exponent)))
+	}
+	return a.Cmp(b)
+}
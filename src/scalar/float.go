@@ -0,0 +1,57 @@
+package scalar
+
+import (
+	"cmp"
+	"math"
+	"strconv"
+
+	"github.com/ArborDB/arbordb/src/core"
+)
+
+type Float64 float64
+
+var _ core.Expression = Float64(0)
+
+func (f Float64) String() string {
+	return strconv.FormatFloat(float64(f), 'g', -1, 64)
+}
+
+var _ core.LogicalIdentifiable = Float64(0)
+
+// LogicalID identifies -0 as 0 and all NaNs alike, as they compare equal.
+func (f Float64) LogicalID(ctx *core.Context) (core.Identifier, error) {
+	return core.Identifier{
+		Kind: "float64",
+		Key:  strconv.FormatFloat(float64(f.canonical()), 'g', -1, 64),
+	}, nil
+}
+
+// canonical returns 0 for -0 and the same NaN for all NaNs.
+func (f Float64) canonical() Float64 {
+	switch {
+	case f == 0:
+		return 0
+	case math.IsNaN(float64(f)):
+		return Float64(math.NaN())
+	}
+	return f
+}
+
+var _ core.PhysicalIdentifiable = Float64(0)
+
+func (f Float64) PhysicalID(ctx *core.Context) (core.Identifier, error) {
+	return f.LogicalID(ctx)
+}
+
+var _ core.CanonicalIdentifiable = Float64(0)
+
+func (f Float64) CanonicalID(ctx *core.Context) (core.Identifier, error) {
+	return f.LogicalID(ctx)
+}
+
+var _ core.Ordered[Float64] = Float64(0)
+
+// Compare orders NaN before all other values, see cmp.Compare.
+func (f Float64) Compare(to Float64) int {
+	return cmp.Compare(f, to)
+}
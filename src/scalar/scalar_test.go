@@ -0,0 +1,212 @@
+package scalar
+
+import (
+	"crypto/sha256"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/ArborDB/arbordb/src/core"
+	"github.com/ArborDB/arbordb/src/dshash"
+)
+
+func TestDecimal(t *testing.T) {
+	for _, _case := range []struct {
+		input  string
+		output string
+	}{
+		{"0", "0"},
+		{"-0.00", "0"},
+		{"12.340", "12.34"},
+		{"-.5", "-0.5"},
+		{"1.5e-3", "0.0015"},
+		{"1200", "1200"},
+		{"1.2e3", "1200"},
+		{"7e30", "7e30"},
+		{"7e-30", "7e-30"},
+	} {
+		d, err := ParseDecimal(_case.input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d.String() != _case.output {
+			t.Fatalf("%s: expected %s, got %s", _case.input, _case.output, d)
+		}
+		parsed, err := ParseDecimal(d.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if parsed != d {
+			t.Fatalf("%s: round trip mismatch: %#v vs %#v", _case.input, parsed, d)
+		}
+	}
+
+	for _, input := range []string{"", "-", "1.2.3", "1e", "abc", "1.-2"} {
+		if _, err := ParseDecimal(input); err == nil {
+			t.Fatalf("%q: expected error", input)
+		}
+	}
+
+	if NewDecimal(1200, -2) != NewDecimal(12, 0) {
+		t.Fatal("normalized decimals should be equal")
+	}
+	if NewDecimal(15, -1).Compare(NewDecimal(2, 0)) != -1 {
+		t.Fatal("1.5 < 2")
+	}
+	if NewDecimal(-3, 5).Compare(NewDecimal(1, -5)) != -1 {
+		t.Fatal("-300000 < 0.00001")
+	}
+	for _, c := range []struct {
+		a, b Decimal
+		want int
+	}{
+		{NewDecimal(999, 0), NewDecimal(1, 3), -1},
+		{NewDecimal(-999, 0), NewDecimal(-1, 3), 1},
+		{NewDecimal(1234, -2), NewDecimal(123, -1), 1},
+		{Decimal{1200, -2}, Decimal{12, 0}, 0},
+		{Decimal{0, 5}, Decimal{0, -5}, 0},
+		{NewDecimal(math.MinInt64, 0), NewDecimal(-1, 19), 1},
+		{NewDecimal(1, math.MaxInt32), NewDecimal(1, math.MinInt32), 1},
+		{NewDecimal(-1, math.MaxInt32), NewDecimal(1, 0), -1},
+	} {
+		if got := c.a.Compare(c.b); got != c.want {
+			t.Fatalf("%v compared to %v: got %d", c.a, c.b, got)
+		}
+	}
+	// huge exponents are compared without scaling
+	huge, err := ParseDecimal("1e2000000000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if huge.Compare(NewDecimal(1, 0)) != 1 {
+		t.Fatal("1e2000000000 > 1")
+	}
+
+	if d := NewDecimal(100, math.MaxInt32-1); d != (Decimal{10, math.MaxInt32}) {
+		t.Fatalf("got %#v", d)
+	}
+}
+
+func TestDecimalExtremeExponents(t *testing.T) {
+	for _, tc := range []struct {
+		d    Decimal
+		want string
+	}{
+		{Decimal{1, math.MinInt32}, "1e-2147483648"},
+		{Decimal{-15, math.MinInt32}, "-15e-2147483648"},
+		{Decimal{1, math.MaxInt32}, "1e2147483647"},
+		{Decimal{1, -19}, "0.0000000000000000001"},
+		{Decimal{1, -20}, "1e-20"},
+	} {
+		if got := tc.d.String(); got != tc.want {
+			t.Fatalf("%#v: got %q, want %q", tc.d, got, tc.want)
+		}
+		id, err := tc.d.LogicalID(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id.Key != tc.want {
+			t.Fatalf("%#v: got id %v", tc.d, id)
+		}
+		parsed, err := ParseDecimal(tc.want)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if parsed != tc.d {
+			t.Fatalf("%q: got %#v", tc.want, parsed)
+		}
+	}
+}
+
+func TestFloat64ID(t *testing.T) {
+	id := func(f float64) core.Identifier {
+		id, err := Float64(f).LogicalID(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+	if Float64(math.Copysign(0, -1)).Compare(Float64(0)) != 0 {
+		t.Fatal("expected -0 to equal 0")
+	}
+	if id(math.Copysign(0, -1)) != id(0) {
+		t.Fatalf("-0 and 0 have distinct ids: %v vs %v", id(math.Copysign(0, -1)), id(0))
+	}
+	payload := math.Float64frombits(math.Float64bits(math.NaN()) | 1)
+	if !math.IsNaN(payload) {
+		t.Fatal("expected NaN")
+	}
+	if id(payload) != id(math.NaN()) || id(math.Copysign(math.NaN(), -1)) != id(math.NaN()) {
+		t.Fatal("NaNs have distinct ids")
+	}
+	if id(1) == id(-1) {
+		t.Fatal("1 and -1 have the same id")
+	}
+}
+
+func TestUUID(t *testing.T) {
+	u := NewUUID()
+	parsed, err := ParseUUID(u.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed != u {
+		t.Fatalf("round trip mismatch: %v vs %v", parsed, u)
+	}
+	if u.String()[14] != '4' {
+		t.Fatalf("expected version 4, got %v", u)
+	}
+	if _, err := ParseUUID("not-a-uuid"); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestTimestamp(t *testing.T) {
+	now := time.Now()
+	a := NewTimestamp(now)
+	b := NewTimestamp(now.In(time.FixedZone("X", 3600)))
+	if a != b {
+		t.Fatal("normalized timestamps of the same instant should be equal")
+	}
+
+	// hashed as an instant
+	sum := func(value any) string {
+		state := sha256.New()
+		if err := dshash.Hash(state, value); err != nil {
+			t.Fatal(err)
+		}
+		return string(state.Sum(nil))
+	}
+	if sum(Timestamp(now)) != sum(Timestamp(now.In(time.FixedZone("X", 3600)))) {
+		t.Fatal("timestamps of the same instant should hash the same")
+	}
+	if sum(a) == sum(NewTimestamp(now.Add(time.Nanosecond))) {
+		t.Fatal("different instants should hash differently")
+	}
+}
+
+func roundTrip[T core.LogicalIdentifiable](t *testing.T, value T) {
+	t.Helper()
+	data, err := dshash.Marshal(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded T
+	if err := dshash.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	id, _ := value.LogicalID(nil)
+	decodedID, _ := decoded.LogicalID(nil)
+	if id != decodedID {
+		t.Fatalf("round trip mismatch: %v vs %v", id, decodedID)
+	}
+}
+
+func TestCodec(t *testing.T) {
+	roundTrip(t, Bool(true))
+	roundTrip(t, Float64(-1.5))
+	roundTrip(t, Bytes("\x00\xff"))
+	roundTrip(t, NewTimestamp(time.Date(2024, 3, 1, 12, 0, 0, 42, time.UTC)))
+	roundTrip(t, NewDecimal(-12345, -2))
+	roundTrip(t, NewUUID())
+}
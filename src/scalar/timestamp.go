@@ -0,0 +1,63 @@
+package scalar
+
+import (
+	"time"
+
+	"github.com/ArborDB/arbordb/src/core"
+)
+
+// Timestamp is an instant in time. Use NewTimestamp to obtain the normalized
+// form, in which equal instants are equal values.
+type Timestamp time.Time
+
+func NewTimestamp(t time.Time) Timestamp {
+	// drop the location and the monotonic clock reading
+	return Timestamp(t.UTC().Round(0))
+}
+
+func (t Timestamp) Time() time.Time {
+	return time.Time(t)
+}
+
+var _ core.Expression = Timestamp{}
+
+func (t Timestamp) String() string {
+	return time.Time(t).UTC().Format(time.RFC3339Nano)
+}
+
+var _ core.LogicalIdentifiable = Timestamp{}
+
+func (t Timestamp) LogicalID(ctx *core.Context) (core.Identifier, error) {
+	return core.Identifier{
+		Kind: "timestamp",
+		Key:  time.Time(t).UTC().Format(time.RFC3339Nano),
+	}, nil
+}
+
+var _ core.PhysicalIdentifiable = Timestamp{}
+
+func (t Timestamp) PhysicalID(ctx *core.Context) (core.Identifier, error) {
+	return t.LogicalID(ctx)
+}
+
+var _ core.CanonicalIdentifiable = Timestamp{}
+
+func (t Timestamp) CanonicalID(ctx *core.Context) (core.Identifier, error) {
+	return t.LogicalID(ctx)
+}
+
+var _ core.Ordered[Timestamp] = Timestamp{}
+
+func (t Timestamp) Compare(to Timestamp) int {
+	return time.Time(t).Compare(time.Time(to))
+}
+
+// MarshalBinary encodes the instant like time.Time, so Timestamps can be
+// written by encoding/gob.
+func (t Timestamp) MarshalBinary() ([]byte, error) {
+	return time.Time(t).MarshalBinary()
+}
+
+func (t *Timestamp) UnmarshalBinary(data []byte) error {
+	return (*time.Time)(t).UnmarshalBinary(data)
+}
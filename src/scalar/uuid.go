@@ -0,0 +1,68 @@
+package scalar
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/ArborDB/arbordb/src/core"
+)
+
+type UUID [16]byte
+
+// NewUUID returns a random (version 4) UUID.
+func NewUUID() UUID {
+	var u UUID
+	rand.Read(u[:])
+	u[6] = (u[6] & 0x0f) | 0x40
+	u[8] = (u[8] & 0x3f) | 0x80
+	return u
+}
+
+// ParseUUID parses the canonical xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx form.
+func ParseUUID(s string) (UUID, error) {
+	var u UUID
+	if len(s) != 36 || s[8] != '-' || s[13] != '-' || s[18] != '-' || s[23] != '-' {
+		return u, fmt.Errorf("invalid UUID: %q", s)
+	}
+	digits := s[0:8] + s[9:13] + s[14:18] + s[19:23] + s[24:36]
+	if _, err := hex.Decode(u[:], []byte(digits)); err != nil {
+		return u, fmt.Errorf("invalid UUID: %q: %w", s, err)
+	}
+	return u, nil
+}
+
+var _ core.Expression = UUID{}
+
+func (u UUID) String() string {
+	digits := hex.EncodeToString(u[:])
+	return digits[0:8] + "-" + digits[8:12] + "-" + digits[12:16] + "-" + digits[16:20] + "-" + digits[20:32]
+}
+
+var _ core.LogicalIdentifiable = UUID{}
+
+func (u UUID) LogicalID(ctx *core.Context) (core.Identifier, error) {
+	return core.Identifier{
+		Kind: "uuid",
+		Key:  u.String(),
+	}, nil
+}
+
+var _ core.PhysicalIdentifiable = UUID{}
+
+func (u UUID) PhysicalID(ctx *core.Context) (core.Identifier, error) {
+	return u.LogicalID(ctx)
+}
+
+var _ core.CanonicalIdentifiable = UUID{}
+
+func (u UUID) CanonicalID(ctx *core.Context) (core.Identifier, error) {
+	return u.LogicalID(ctx)
+}
+
+var _ core.Ordered[UUID] = UUID{}
+
+func (u UUID) Compare(to UUID) int {
+	return bytes.Compare(u[:], to[:])
+}
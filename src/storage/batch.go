@@ -0,0 +1,77 @@
+package storage
+
+import (
+	"iter"
+
+	"github.com/ArborDB/arbordb/src/core"
+)
+
+// Batch is a PhysicalStorage storing and loading many expressions at once.
+type Batch interface {
+	core.PhysicalStorage
+	// SetMany stores exprs and returns their identifiers, in the same order.
+	SetMany(ctx *core.Context, exprs []core.Expression) ([]core.Identifier, error)
+	// GetMany loads the expressions of ids, in the same order.
+	GetMany(ctx *core.Context, ids []core.Identifier) ([]core.Expression, error)
+	// HasMany yields whether each of ids is stored, as ids are consumed. It
+	// stops after yielding an error.
+	HasMany(ctx *core.Context, ids iter.Seq[core.Identifier]) iter.Seq2[Existence, error]
+}
+
+// Existence is whether an identifier is stored.
+type Existence struct {
+	ID     core.Identifier
+	Stored bool
+}
+
+// Batched returns s as a Batch, looping over Set, Get and Has if s does not
+// implement Batch.
+func Batched(s core.PhysicalStorage) Batch {
+	if batch, ok := s.(Batch); ok {
+		return batch
+	}
+	return batched{s}
+}
+
+type batched struct {
+	core.PhysicalStorage
+}
+
+func (b batched) SetMany(ctx *core.Context, exprs []core.Expression) ([]core.Identifier, error) {
+	ids := make([]core.Identifier, 0, len(exprs))
+	for _, expr := range exprs {
+		id, err := b.Set(ctx, expr)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+func (b batched) GetMany(ctx *core.Context, ids []core.Identifier) ([]core.Expression, error) {
+	exprs := make([]core.Expression, 0, len(ids))
+	for _, id := range ids {
+		var expr core.Expression
+		if err := b.Get(ctx, id, &expr); err != nil {
+			return nil, err
+		}
+		exprs = append(exprs, expr)
+	}
+	return exprs, nil
+}
+
+func (b batched) HasMany(ctx *core.Context, ids iter.Seq[core.Identifier]) iter.Seq2[Existence, error] {
+	return func(yield func(Existence, error) bool) {
+		for id := range ids {
+			ok, err := b.Has(ctx, id)
+			if err != nil {
+				yield(Existence{ID: id}, err)
+				return
+			}
+			if !yield(Existence{ID: id, Stored: ok}, nil) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package storage
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/ArborDB/arbordb/src/core"
+)
+
+func TestBatch(t *testing.T) {
+	for name, s := range map[string]Batch{
+		"memory":   NewMemory(),
+		"fallback": Batched(NewCached(NewMemory(), CacheOptions{})),
+	} {
+		t.Run(name, func(t *testing.T) {
+			exprs := []core.Expression{node{Name: "a"}, node{Name: "b"}}
+			ids, err := s.SetMany(nil, exprs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, expr := range exprs {
+				if id, err := Identify(expr); err != nil || id != ids[i] {
+					t.Fatalf("got %v, want %v", ids[i], id)
+				}
+			}
+
+			got, err := s.GetMany(nil, []core.Identifier{ids[1], ids[0]})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got[0].String() != "b" || got[1].String() != "a" {
+				t.Fatalf("got %v", got)
+			}
+			missing := core.Identifier{Kind: IdentifierKind, Key: "missing"}
+			if _, err := s.GetMany(nil, []core.Identifier{ids[0], missing}); err == nil {
+				t.Fatal("expected error")
+			}
+
+			var stored []bool
+			for existence, err := range s.HasMany(nil, slices.Values([]core.Identifier{ids[0], missing, ids[1]})) {
+				if err != nil {
+					t.Fatal(err)
+				}
+				stored = append(stored, existence.Stored)
+				if existence.ID == missing {
+					// stops early
+					break
+				}
+			}
+			if !slices.Equal(stored, []bool{true, false}) {
+				t.Fatalf("got %v", stored)
+			}
+		})
+	}
+}
+
+// unavailable fails existence checks
+type unavailable struct {
+	*Memory
+}
+
+func (u unavailable) Has(ctx *core.Context, id core.Identifier) (bool, error) {
+	return false, errors.New("unavailable")
+}
+
+func TestBatchHasManyError(t *testing.T) {
+	s := Batched(NewCached(unavailable{NewMemory()}, CacheOptions{}))
+	ids := []core.Identifier{{Kind: IdentifierKind, Key: "a"}, {Kind: IdentifierKind, Key: "b"}}
+	n := 0
+	for _, err := range s.HasMany(nil, slices.Values(ids)) {
+		n++
+		if err == nil {
+			t.Fatal("expected error")
+		}
+	}
+	// stops after the error
+	if n != 1 {
+		t.Fatalf("yielded %d", n)
+	}
+}
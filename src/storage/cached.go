@@ -0,0 +1,172 @@
+package storage
+
+import (
+	"container/list"
+	"iter"
+	"sync"
+
+	"github.com/ArborDB/arbordb/src/core"
+	"github.com/ArborDB/arbordb/src/dshash"
+)
+
+type CacheOptions struct {
+	// MaxBytes is the budget of the sizes of the cached expressions.
+	MaxBytes int64
+	// Size estimates the bytes of an expression. The default is the length of
+	// its dshash encoding.
+	Size func(expr core.Expression) (int64, error)
+	// Admit reports whether to cache an expression stored or loaded from the
+	// backend. The default admits all expressions within MaxBytes.
+	Admit func(id core.Identifier, size int64) bool
+}
+
+type CacheStats struct {
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+	// expressions not admitted
+	Rejections uint64
+	Entries    int
+	Bytes      int64
+}
+
+// Cached is a PhysicalStorage keeping the expressions last stored to or
+// loaded from a slower backend in memory, evicting the least recently used
+// ones beyond its budget. It is safe for concurrent use if the backend is.
+type Cached struct {
+	backend core.PhysicalStorage
+	options CacheOptions
+
+	mu      sync.Mutex
+	entries map[core.Identifier]*list.Element
+	// of *cacheEntry, most recently used first
+	lru   *list.List
+	stats CacheStats
+}
+
+type cacheEntry struct {
+	id   core.Identifier
+	expr core.Expression
+	size int64
+}
+
+func NewCached(backend core.PhysicalStorage, options CacheOptions) *Cached {
+	if options.Size == nil {
+		options.Size = encodedSize
+	}
+	return &Cached{
+		backend: backend,
+		options: options,
+		entries: make(map[core.Identifier]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+var _ core.PhysicalStorage = (*Cached)(nil)
+
+func (c *Cached) Set(ctx *core.Context, expr core.Expression) (core.Identifier, error) {
+	id, err := c.backend.Set(ctx, expr)
+	if err != nil {
+		return id, err
+	}
+	c.add(id, expr)
+	return id, nil
+}
+
+func (c *Cached) Get(ctx *core.Context, id core.Identifier, target any) error {
+	c.mu.Lock()
+	if elem, ok := c.entries[id]; ok {
+		c.lru.MoveToFront(elem)
+		c.stats.Hits++
+		expr := elem.Value.(*cacheEntry).expr
+		c.mu.Unlock()
+		return assign(target, expr)
+	}
+	c.stats.Misses++
+	c.mu.Unlock()
+
+	var expr core.Expression
+	if err := c.backend.Get(ctx, id, &expr); err != nil {
+		return err
+	}
+	c.add(id, expr)
+	return assign(target, expr)
+}
+
+func (c *Cached) Has(ctx *core.Context, id core.Identifier) (bool, error) {
+	c.mu.Lock()
+	_, ok := c.entries[id]
+	c.mu.Unlock()
+	if ok {
+		return true, nil
+	}
+	return c.backend.Has(ctx, id)
+}
+
+func (c *Cached) Delete(ctx *core.Context, id core.Identifier) error {
+	if err := c.backend.Delete(ctx, id); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[id]; ok {
+		entry := c.lru.Remove(elem).(*cacheEntry)
+		delete(c.entries, id)
+		c.stats.Bytes -= entry.size
+	}
+	return nil
+}
+
+func (c *Cached) Iterate(ctx *core.Context) iter.Seq2[core.Identifier, error] {
+	return c.backend.Iterate(ctx)
+}
+
+// Stats returns the metrics of the cache.
+func (c *Cached) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := c.stats
+	stats.Entries = c.lru.Len()
+	return stats
+}
+
+// add caches expr if admitted, evicting the least recently used expressions
+// beyond the budget.
+func (c *Cached) add(id core.Identifier, expr core.Expression) {
+	size, err := c.options.Size(expr)
+	admitted := err == nil && size <= c.options.MaxBytes
+	if admitted && c.options.Admit != nil {
+		admitted = c.options.Admit(id, size)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !admitted {
+		c.stats.Rejections++
+		return
+	}
+	if elem, ok := c.entries[id]; ok {
+		c.lru.MoveToFront(elem)
+		return
+	}
+	c.entries[id] = c.lru.PushFront(&cacheEntry{
+		id:   id,
+		expr: expr,
+		size: size,
+	})
+	c.stats.Bytes += size
+	for c.stats.Bytes > c.options.MaxBytes {
+		entry := c.lru.Remove(c.lru.Back()).(*cacheEntry)
+		delete(c.entries, entry.id)
+		c.stats.Bytes -= entry.size
+		c.stats.Evictions++
+	}
+}
+
+func encodedSize(expr core.Expression) (int64, error) {
+	data, err := dshash.Marshal(expr)
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(data)), nil
+}
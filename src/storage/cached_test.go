@@ -0,0 +1,102 @@
+package storage
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/ArborDB/arbordb/src/core"
+	"github.com/ArborDB/arbordb/src/scalar"
+)
+
+// countingBackend counts the loads of a Memory
+type countingBackend struct {
+	*Memory
+	mu   sync.Mutex
+	gets int
+}
+
+func (c *countingBackend) Get(ctx *core.Context, id core.Identifier, target any) error {
+	c.mu.Lock()
+	c.gets++
+	c.mu.Unlock()
+	return c.Memory.Get(ctx, id, target)
+}
+
+func TestCached(t *testing.T) {
+	backend := &countingBackend{Memory: NewMemory()}
+	var ids []core.Identifier
+	for _, s := range []string{"a", "b", "c"} {
+		id, err := backend.Memory.Set(nil, scalar.String(s))
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+
+	cached := NewCached(backend, CacheOptions{
+		// two expressions
+		MaxBytes: 2,
+		Size: func(expr core.Expression) (int64, error) {
+			return 1, nil
+		},
+	})
+	get := func(id core.Identifier) {
+		t.Helper()
+		var s scalar.String
+		if err := cached.Get(nil, id, &s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	get(ids[0])
+	get(ids[1])
+	get(ids[0])
+	// evicts b, the least recently used
+	get(ids[2])
+	get(ids[0])
+	get(ids[1])
+	want := CacheStats{
+		Hits:      2,
+		Misses:    4,
+		Evictions: 2,
+		Entries:   2,
+		Bytes:     2,
+	}
+	if got := cached.Stats(); got != want {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+	if backend.gets != 4 {
+		t.Fatalf("got %d loads", backend.gets)
+	}
+
+	// stored expressions are cached, unless rejected
+	cached = NewCached(backend, CacheOptions{
+		MaxBytes: 1 << 20,
+		Admit: func(id core.Identifier, size int64) bool {
+			return id != ids[1]
+		},
+	})
+	id, err := cached.Set(nil, scalar.String("d"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	get(id)
+	get(ids[1])
+	get(ids[1])
+	if got := cached.Stats(); got.Hits != 1 || got.Misses != 2 || got.Rejections != 2 || got.Entries != 1 {
+		t.Fatalf("got %+v", got)
+	}
+
+	// concurrent use
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Go(func() {
+			for _, id := range ids {
+				var s scalar.String
+				if err := cached.Get(nil, id, &s); err != nil {
+					t.Error(err)
+				}
+			}
+		})
+	}
+	wg.Wait()
+}
@@ -0,0 +1,137 @@
+package storage
+
+import (
+	"bytes"
+	"compress/flate"
+	"fmt"
+	"io"
+	"iter"
+
+	"github.com/ArborDB/arbordb/src/core"
+)
+
+const (
+	encodingGob      = "gob"
+	encodingGobFlate = "gob+flate"
+)
+
+type CompressOptions struct {
+	// MinSize is the encoded size from which expressions are compressed. The
+	// default is 128 bytes.
+	MinSize int
+	// Level returns the flate level compressing an encoded expression of size
+	// bytes. The default is flate.BestSpeed, and flate.BestCompression from
+	// 64 KiB.
+	Level func(size int) int
+}
+
+// Compressed is a PhysicalStorage storing the expressions compressed in a
+// backend, under the identifiers of the uncompressed expressions. The types
+// of the expressions must be registered with RegisterType.
+type Compressed struct {
+	backend Keyed
+	options CompressOptions
+}
+
+func NewCompressed(backend Keyed, options CompressOptions) *Compressed {
+	if options.MinSize == 0 {
+		options.MinSize = 128
+	}
+	if options.Level == nil {
+		options.Level = defaultLevel
+	}
+	return &Compressed{
+		backend: backend,
+		options: options,
+	}
+}
+
+var _ Keyed = (*Compressed)(nil)
+
+func (c *Compressed) Set(ctx *core.Context, expr core.Expression) (core.Identifier, error) {
+	id, err := Identify(expr)
+	if err != nil {
+		return core.Identifier{}, err
+	}
+	return id, c.SetAs(ctx, id, expr)
+}
+
+func (c *Compressed) SetAs(ctx *core.Context, id core.Identifier, expr core.Expression) error {
+	data, err := encodeExpression(expr)
+	if err != nil {
+		return err
+	}
+	env := Envelope{
+		Encoding: encodingGob,
+		Data:     data,
+	}
+	if len(data) >= c.options.MinSize {
+		compressed, err := compress(data, c.options.Level(len(data)))
+		if err != nil {
+			return fmt.Errorf("compress %v: %w", id, err)
+		}
+		// incompressible data is stored as is
+		if len(compressed) < len(data) {
+			env.Encoding = encodingGobFlate
+			env.Data = compressed
+		}
+	}
+	return c.backend.SetAs(ctx, id, env)
+}
+
+func (c *Compressed) Get(ctx *core.Context, id core.Identifier, target any) error {
+	env, err := loadEnvelope(ctx, c.backend, id)
+	if err != nil {
+		return err
+	}
+	data := env.Data
+	switch env.Encoding {
+	case encodingGob:
+	case encodingGobFlate:
+		data, err = io.ReadAll(flate.NewReader(bytes.NewReader(env.Data)))
+		if err != nil {
+			return fmt.Errorf("decompress %v: %w", id, err)
+		}
+	default:
+		return fmt.Errorf("%v: unknown encoding %q", id, env.Encoding)
+	}
+	expr, err := decodeExpression(data)
+	if err != nil {
+		return fmt.Errorf("%v: %w", id, err)
+	}
+	return assign(target, expr)
+}
+
+func (c *Compressed) Has(ctx *core.Context, id core.Identifier) (bool, error) {
+	return c.backend.Has(ctx, id)
+}
+
+func (c *Compressed) Delete(ctx *core.Context, id core.Identifier) error {
+	return c.backend.Delete(ctx, id)
+}
+
+func (c *Compressed) Iterate(ctx *core.Context) iter.Seq2[core.Identifier, error] {
+	return c.backend.Iterate(ctx)
+}
+
+func compress(data []byte, level int) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := flate.NewWriter(&buf, level)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func defaultLevel(size int) int {
+	if size >= 64<<10 {
+		return flate.BestCompression
+	}
+	return flate.BestSpeed
+}
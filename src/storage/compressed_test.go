@@ -0,0 +1,115 @@
+package storage
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/ArborDB/arbordb/src/collection"
+	"github.com/ArborDB/arbordb/src/core"
+	"github.com/ArborDB/arbordb/src/scalar"
+)
+
+func init() {
+	RegisterType(node{})
+}
+
+func TestCompressedEncrypted(t *testing.T) {
+	backend := NewMemory()
+	keys := NewKeyring()
+	if err := keys.Add("k1", make([]byte, 32)); err != nil {
+		t.Fatal(err)
+	}
+	s := NewCompressed(NewEncrypted(backend, keys), CompressOptions{})
+
+	small := node{Name: "small"}
+	large := node{Name: strings.Repeat("large", 1000)}
+	for _, expr := range []node{small, large} {
+		want, err := Identify(expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// identified by the plain expression
+		id, err := s.Set(nil, expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id != want {
+			t.Fatalf("got %v, want %v", id, want)
+		}
+		var got node
+		if err := s.Get(nil, id, &got); err != nil {
+			t.Fatal(err)
+		}
+		if got.Name != expr.Name {
+			t.Fatalf("got %v", got)
+		}
+
+		var env Envelope
+		if err := backend.Get(nil, id, &env); err != nil {
+			t.Fatal(err)
+		}
+		if env.KeyID != "k1" || strings.Contains(string(env.Data), "small") {
+			t.Fatalf("got %v", env)
+		}
+		if len(env.Data) > 1000 {
+			t.Fatalf("not compressed: %v", env)
+		}
+	}
+
+	// rotation
+	id, err := Identify(small)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := keys.Add("k2", []byte("0123456789abcdef")); err != nil {
+		t.Fatal(err)
+	}
+	if err := keys.Rotate("k3"); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("got %v", err)
+	}
+	if err := keys.Rotate("k2"); err != nil {
+		t.Fatal(err)
+	}
+	encrypted := NewEncrypted(backend, keys)
+	if err := encrypted.Rekey(nil, id); err != nil {
+		t.Fatal(err)
+	}
+	var env Envelope
+	if err := backend.Get(nil, id, &env); err != nil {
+		t.Fatal(err)
+	}
+	if env.KeyID != "k2" {
+		t.Fatalf("got %v", env)
+	}
+	var got node
+	if err := s.Get(nil, id, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != small.Name {
+		t.Fatalf("got %v", got)
+	}
+
+	// scalars and collections are registered
+	dict := collection.Map[scalar.String, core.Expression]{"k": scalar.Int(1)}
+	dictID, err := s.Set(nil, dict)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var loaded collection.Map[scalar.String, core.Expression]
+	if err := s.Get(nil, dictID, &loaded); err != nil {
+		t.Fatal(err)
+	}
+	if loaded["k"] != scalar.Int(1) {
+		t.Fatalf("got %v", loaded)
+	}
+
+	// envelopes are bound to their identifiers
+	other := core.Identifier{Kind: IdentifierKind, Key: "other"}
+	if err := backend.SetAs(nil, other, env); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Get(nil, other, &got); err == nil {
+		t.Fatal("expected error")
+	}
+}
@@ -0,0 +1,190 @@
+package storage
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"iter"
+	"sync"
+
+	"github.com/ArborDB/arbordb/src/core"
+)
+
+const encodingGobAESGCM = "gob+aes-gcm"
+
+var ErrUnknownKey = errors.New("unknown encryption key")
+
+// Keyring holds the keys of an Encrypted. Expressions are encrypted with the
+// current key, and decrypted with the key they were encrypted with, so that
+// keys can be rotated without re-encrypting the stored expressions at once.
+type Keyring struct {
+	mu      sync.RWMutex
+	keys    map[string]cipher.AEAD
+	current string
+}
+
+func NewKeyring() *Keyring {
+	return &Keyring{
+		keys: make(map[string]cipher.AEAD),
+	}
+}
+
+// Add adds an AES key of 16, 24 or 32 bytes. The first key added becomes the
+// current key.
+func (k *Keyring) Add(keyID string, key []byte) error {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return err
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.keys[keyID] = aead
+	if k.current == "" {
+		k.current = keyID
+	}
+	return nil
+}
+
+// Rotate makes the key keyID the current key.
+func (k *Keyring) Rotate(keyID string) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if _, ok := k.keys[keyID]; !ok {
+		return fmt.Errorf("%w: %q", ErrUnknownKey, keyID)
+	}
+	k.current = keyID
+	return nil
+}
+
+// Current returns the identifier of the current key.
+func (k *Keyring) Current() string {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.current
+}
+
+func (k *Keyring) key(keyID string) (cipher.AEAD, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	aead, ok := k.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKey, keyID)
+	}
+	return aead, nil
+}
+
+// Encrypted is a PhysicalStorage storing the expressions encrypted with
+// AES-GCM in a backend, under the identifiers of the plain expressions. The
+// identifier is authenticated with the expression, so that the stored forms
+// of two expressions cannot be swapped. The types of the expressions must be
+// registered with RegisterType.
+type Encrypted struct {
+	backend Keyed
+	keys    *Keyring
+}
+
+func NewEncrypted(backend Keyed, keys *Keyring) *Encrypted {
+	return &Encrypted{
+		backend: backend,
+		keys:    keys,
+	}
+}
+
+var _ Keyed = (*Encrypted)(nil)
+
+func (e *Encrypted) Set(ctx *core.Context, expr core.Expression) (core.Identifier, error) {
+	id, err := Identify(expr)
+	if err != nil {
+		return core.Identifier{}, err
+	}
+	return id, e.SetAs(ctx, id, expr)
+}
+
+func (e *Encrypted) SetAs(ctx *core.Context, id core.Identifier, expr core.Expression) error {
+	keyID := e.keys.Current()
+	aead, err := e.keys.key(keyID)
+	if err != nil {
+		return err
+	}
+	data, err := encodeExpression(expr)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(data)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	return e.backend.SetAs(ctx, id, Envelope{
+		Encoding: encodingGobAESGCM,
+		KeyID:    keyID,
+		Data:     aead.Seal(nonce, nonce, data, []byte(id.String())),
+	})
+}
+
+func (e *Encrypted) Get(ctx *core.Context, id core.Identifier, target any) error {
+	expr, _, err := e.load(ctx, id)
+	if err != nil {
+		return err
+	}
+	return assign(target, expr)
+}
+
+func (e *Encrypted) Has(ctx *core.Context, id core.Identifier) (bool, error) {
+	return e.backend.Has(ctx, id)
+}
+
+func (e *Encrypted) Delete(ctx *core.Context, id core.Identifier) error {
+	return e.backend.Delete(ctx, id)
+}
+
+func (e *Encrypted) Iterate(ctx *core.Context) iter.Seq2[core.Identifier, error] {
+	return e.backend.Iterate(ctx)
+}
+
+// Rekey re-encrypts the expression of id with the current key, if encrypted
+// with another one. Once all are re-encrypted, the previous keys can be
+// dropped.
+func (e *Encrypted) Rekey(ctx *core.Context, id core.Identifier) error {
+	expr, keyID, err := e.load(ctx, id)
+	if err != nil {
+		return err
+	}
+	if keyID == e.keys.Current() {
+		return nil
+	}
+	return e.SetAs(ctx, id, expr)
+}
+
+// load returns the expression of id and the key it is encrypted with.
+func (e *Encrypted) load(ctx *core.Context, id core.Identifier) (core.Expression, string, error) {
+	env, err := loadEnvelope(ctx, e.backend, id)
+	if err != nil {
+		return nil, "", err
+	}
+	if env.Encoding != encodingGobAESGCM {
+		return nil, "", fmt.Errorf("%v: unknown encoding %q", id, env.Encoding)
+	}
+	aead, err := e.keys.key(env.KeyID)
+	if err != nil {
+		return nil, "", fmt.Errorf("%v: %w", id, err)
+	}
+	if len(env.Data) < aead.NonceSize() {
+		return nil, "", fmt.Errorf("%v: ciphertext too short", id)
+	}
+	nonce, ciphertext := env.Data[:aead.NonceSize()], env.Data[aead.NonceSize():]
+	data, err := aead.Open(nil, nonce, ciphertext, []byte(id.String()))
+	if err != nil {
+		return nil, "", fmt.Errorf("decrypt %v: %w", id, err)
+	}
+	expr, err := decodeExpression(data)
+	if err != nil {
+		return nil, "", fmt.Errorf("%v: %w", id, err)
+	}
+	return expr, env.KeyID, nil
+}
@@ -0,0 +1,90 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+
+	"github.com/ArborDB/arbordb/src/core"
+	"github.com/ArborDB/arbordb/src/scalar"
+)
+
+// Keyed is a PhysicalStorage that also stores expressions under identifiers
+// given by the caller. Wrappers transforming expressions, like Compressed and
+// Encrypted, store the transformed forms in a Keyed under the identifiers of
+// the plain expressions, so that identical expressions are still stored once.
+type Keyed interface {
+	core.PhysicalStorage
+	SetAs(ctx *core.Context, id core.Identifier, expr core.Expression) error
+}
+
+// Envelope is the stored form of an expression transformed by a wrapper.
+type Envelope struct {
+	Encoding string
+	// the key encrypting Data, if encrypted
+	KeyID string
+	Data  []byte
+}
+
+var _ core.Expression = Envelope{}
+
+func (e Envelope) String() string {
+	return fmt.Sprintf("Envelope{%s %s %d bytes}", e.Encoding, e.KeyID, len(e.Data))
+}
+
+// RegisterType registers the concrete type of value to be encoded by the
+// wrappers storing expressions in encoded form, like Compressed and
+// Encrypted. The types of the scalar values are registered, and the
+// collection package registers its collections of expressions by String.
+func RegisterType(value core.Expression) {
+	gob.Register(value)
+}
+
+func init() {
+	for _, value := range []core.Expression{
+		scalar.Bool(false),
+		scalar.Bytes(""),
+		scalar.Decimal{},
+		scalar.Float64(0),
+		scalar.Int(0),
+		scalar.String(""),
+		scalar.Timestamp{},
+		scalar.UUID{},
+		core.Identifier{},
+		Envelope{},
+	} {
+		RegisterType(value)
+	}
+}
+
+// encodeExpression encodes expr with gob. The canonical dshash encoding
+// carries no types to decode expressions into, so the concrete types of
+// expressions must be registered with RegisterType.
+func encodeExpression(expr core.Expression) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&expr); err != nil {
+		return nil, fmt.Errorf("encode %T: %w", expr, err)
+	}
+	return buf.Bytes(), nil
+}
+
+func decodeExpression(data []byte) (core.Expression, error) {
+	var expr core.Expression
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&expr); err != nil {
+		return nil, fmt.Errorf("decode: %w", err)
+	}
+	return expr, nil
+}
+
+// loadEnvelope loads the envelope stored under id in s.
+func loadEnvelope(ctx *core.Context, s core.PhysicalStorage, id core.Identifier) (Envelope, error) {
+	var expr core.Expression
+	if err := s.Get(ctx, id, &expr); err != nil {
+		return Envelope{}, err
+	}
+	env, ok := expr.(Envelope)
+	if !ok {
+		return Envelope{}, fmt.Errorf("%w: %v is not an Envelope, got %T", ErrTypeMismatch, id, expr)
+	}
+	return env, nil
+}
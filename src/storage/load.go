@@ -0,0 +1,115 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/ArborDB/arbordb/src/core"
+)
+
+var ErrTypeMismatch = errors.New("type mismatch")
+
+type transformFunc = func(ctx *core.Context, from core.Expression) (core.Expression, error)
+
+type registeredTransform struct {
+	from  reflect.Type
+	to    reflect.Type
+	apply transformFunc
+}
+
+var (
+	transformsMu sync.RWMutex
+	// in registration order
+	transforms []registeredTransform
+)
+
+// RegisterTransform registers t to convert the stored expressions that are a
+// From to a To, when loaded as a To or an interface To implements.
+func RegisterTransform[From core.Expression, To core.Expression](t core.Transform[From, To]) {
+	transformsMu.Lock()
+	defer transformsMu.Unlock()
+	transforms = append(transforms, registeredTransform{
+		from: reflect.TypeFor[From](),
+		to:   reflect.TypeFor[To](),
+		apply: func(ctx *core.Context, from core.Expression) (core.Expression, error) {
+			var to To
+			if err := t.Apply(ctx, from.(From), &to); err != nil {
+				return nil, err
+			}
+			return to, nil
+		},
+	})
+}
+
+// transformFor returns the transform converting a from to a to, preferring
+// one registered for these exact types.
+func transformFor(from reflect.Type, to reflect.Type) transformFunc {
+	transformsMu.RLock()
+	defer transformsMu.RUnlock()
+	for _, t := range transforms {
+		if t.from == from && t.to == to {
+			return t.apply
+		}
+	}
+	for _, t := range transforms {
+		if from.AssignableTo(t.from) && t.to.AssignableTo(to) {
+			return t.apply
+		}
+	}
+	return nil
+}
+
+// Load returns the expression stored under id as a T, converted by a
+// registered Transform if it is not a T.
+func Load[T core.Expression](ctx *core.Context, s core.PhysicalStorage, id core.Identifier) (T, error) {
+	var zero T
+	var expr core.Expression
+	if err := s.Get(ctx, id, &expr); err != nil {
+		return zero, err
+	}
+	if typed, ok := expr.(T); ok {
+		return typed, nil
+	}
+
+	to := reflect.TypeFor[T]()
+	apply := transformFor(reflect.TypeOf(expr), to)
+	if apply == nil {
+		return zero, fmt.Errorf("%w: %v holds %T, not %v", ErrTypeMismatch, id, expr, to)
+	}
+	converted, err := apply(ctx, expr)
+	if err != nil {
+		return zero, fmt.Errorf("convert %T to %v: %w", expr, to, err)
+	}
+	typed, ok := converted.(T)
+	if !ok {
+		return zero, fmt.Errorf("%w: %T converted to %T, not %v", ErrTypeMismatch, expr, converted, to)
+	}
+	return typed, nil
+}
+
+// Handle is the identifier of a stored T.
+type Handle[T core.Expression] struct {
+	ID core.Identifier
+}
+
+// Store stores value and returns its Handle.
+func Store[T core.Expression](ctx *core.Context, s core.PhysicalStorage, value T) (Handle[T], error) {
+	id, err := s.Set(ctx, value)
+	if err != nil {
+		return Handle[T]{}, err
+	}
+	return Handle[T]{ID: id}, nil
+}
+
+var _ core.Expression = Handle[core.Identifier]{}
+
+func (h Handle[T]) String() string {
+	return fmt.Sprintf("Handle[%v](%v)", reflect.TypeFor[T](), h.ID)
+}
+
+// Load returns the stored T, see Load.
+func (h Handle[T]) Load(ctx *core.Context, s core.PhysicalStorage) (T, error) {
+	return Load[T](ctx, s, h.ID)
+}
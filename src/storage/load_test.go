@@ -0,0 +1,50 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ArborDB/arbordb/src/core"
+	"github.com/ArborDB/arbordb/src/scalar"
+)
+
+// nodeName converts a node to its name
+type nodeName struct{}
+
+func (nodeName) EstimateCost(ctx *core.Context, from node) (core.Cost, error) {
+	return core.Cost{}, nil
+}
+
+func (nodeName) Apply(ctx *core.Context, from node, to *scalar.String) error {
+	*to = scalar.String(from.Name)
+	return nil
+}
+
+func TestLoad(t *testing.T) {
+	m := NewMemory()
+	handle, err := Store(nil, m, node{Name: "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := handle.Load(nil, m); err != nil || n.Name != "a" {
+		t.Fatalf("got %v, %v", n, err)
+	}
+	if expr, err := Load[core.Expression](nil, m, handle.ID); err != nil || expr.String() != "a" {
+		t.Fatalf("got %v, %v", expr, err)
+	}
+	if _, err := Load[scalar.Int](nil, m, handle.ID); !errors.Is(err, ErrTypeMismatch) {
+		t.Fatalf("expected type mismatch, got %v", err)
+	}
+	if _, err := Load[core.Ordered[scalar.String]](nil, m, handle.ID); !errors.Is(err, ErrTypeMismatch) {
+		t.Fatalf("expected type mismatch, got %v", err)
+	}
+
+	// converted by registered transforms
+	RegisterTransform(core.Transform[node, scalar.String](nodeName{}))
+	if s, err := Load[scalar.String](nil, m, handle.ID); err != nil || s != "a" {
+		t.Fatalf("got %v, %v", s, err)
+	}
+	if s, err := Load[core.Ordered[scalar.String]](nil, m, handle.ID); err != nil || s.Compare("a") != 0 {
+		t.Fatalf("got %v, %v", s, err)
+	}
+}
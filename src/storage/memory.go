@@ -4,7 +4,10 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
+	"iter"
+	"maps"
 	"reflect"
+	"slices"
 	"sync"
 
 	"github.com/ArborDB/arbordb/src/core"
@@ -22,16 +25,30 @@ func NewMemory() *Memory {
 	}
 }
 
-var _ core.PhysicalStorage = (*Memory)(nil)
+var _ Keyed = (*Memory)(nil)
 
-func (m *Memory) Set(ctx *core.Context, expr core.Expression) (core.Identifier, error) {
+var _ Batch = (*Memory)(nil)
+
+// IdentifierKind is the kind of the identifiers returned by Identify.
+const IdentifierKind = "dshash-sha256"
+
+// Identify returns the content identifier of an expression, the SHA-256 of
+// its dshash.
+func Identify(expr core.Expression) (core.Identifier, error) {
 	state := sha256.New()
 	if err := dshash.Hash(state, expr); err != nil {
 		return core.Identifier{}, err
 	}
-	id := core.Identifier{
-		Kind: "dshash-sha256",
+	return core.Identifier{
+		Kind: IdentifierKind,
 		Key:  hex.EncodeToString(state.Sum(nil)),
+	}, nil
+}
+
+func (m *Memory) Set(ctx *core.Context, expr core.Expression) (core.Identifier, error) {
+	id, err := Identify(expr)
+	if err != nil {
+		return core.Identifier{}, err
 	}
 
 	m.mu.Lock()
@@ -40,14 +57,99 @@ func (m *Memory) Set(ctx *core.Context, expr core.Expression) (core.Identifier,
 	return id, nil
 }
 
+func (m *Memory) SetAs(ctx *core.Context, id core.Identifier, expr core.Expression) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.items[id] = expr
+	return nil
+}
+
 func (m *Memory) Get(ctx *core.Context, id core.Identifier, target any) error {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 	expr, ok := m.items[id]
 	if !ok {
-		return fmt.Errorf("item not found: %v", id)
+		return fmt.Errorf("%v: %w", id, core.Err[core.ErrNotFound]())
 	}
+	return assign(target, expr)
+}
+
+func (m *Memory) Has(ctx *core.Context, id core.Identifier) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	_, ok := m.items[id]
+	return ok, nil
+}
+
+func (m *Memory) Delete(ctx *core.Context, id core.Identifier) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.items, id)
+	return nil
+}
+
+func (m *Memory) Iterate(ctx *core.Context) iter.Seq2[core.Identifier, error] {
+	return func(yield func(core.Identifier, error) bool) {
+		// not held while yielding
+		m.mu.RLock()
+		ids := slices.Collect(maps.Keys(m.items))
+		m.mu.RUnlock()
+		for _, id := range ids {
+			if !yield(id, nil) {
+				return
+			}
+		}
+	}
+}
+
+func (m *Memory) SetMany(ctx *core.Context, exprs []core.Expression) ([]core.Identifier, error) {
+	ids := make([]core.Identifier, 0, len(exprs))
+	for _, expr := range exprs {
+		id, err := Identify(expr)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, id := range ids {
+		m.items[id] = exprs[i]
+	}
+	return ids, nil
+}
+
+func (m *Memory) GetMany(ctx *core.Context, ids []core.Identifier) ([]core.Expression, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	exprs := make([]core.Expression, 0, len(ids))
+	for _, id := range ids {
+		expr, ok := m.items[id]
+		if !ok {
+			return nil, fmt.Errorf("%v: %w", id, core.Err[core.ErrNotFound]())
+		}
+		exprs = append(exprs, expr)
+	}
+	return exprs, nil
+}
+
+func (m *Memory) HasMany(ctx *core.Context, ids iter.Seq[core.Identifier]) iter.Seq2[Existence, error] {
+	return func(yield func(Existence, error) bool) {
+		for id := range ids {
+			// not held while yielding
+			m.mu.RLock()
+			_, ok := m.items[id]
+			m.mu.RUnlock()
+			if !yield(Existence{ID: id, Stored: ok}, nil) {
+				return
+			}
+		}
+	}
+}
 
+// assign sets the value target points to to expr.
+func assign(target any, expr core.Expression) error {
 	targetVal := reflect.ValueOf(target)
 	if targetVal.Kind() != reflect.Pointer || targetVal.IsNil() {
 		return fmt.Errorf("target must be a non-nil pointer")
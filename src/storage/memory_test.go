@@ -0,0 +1,49 @@
+package storage
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/ArborDB/arbordb/src/core"
+)
+
+func TestMemory(t *testing.T) {
+	m := NewMemory()
+	a := set(t, m, node{Name: "a"})
+	b := set(t, m, node{Name: "b"})
+	// deletions are seen through a cache
+	s := NewCached(m, CacheOptions{MaxBytes: 1 << 20})
+	var expr core.Expression
+	if err := s.Get(nil, a, &expr); err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, err := s.Has(nil, a); err != nil || !ok {
+		t.Fatalf("got %v, %v", ok, err)
+	}
+	if err := s.Delete(nil, a); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := s.Has(nil, a); err != nil || ok {
+		t.Fatalf("got %v, %v", ok, err)
+	}
+	if err := s.Get(nil, a, &expr); !errors.Is(err, core.ErrNotFound{}) {
+		t.Fatalf("got %v", err)
+	}
+	// deleting an absent object is not an error
+	if err := s.Delete(nil, a); err != nil {
+		t.Fatal(err)
+	}
+
+	var ids []core.Identifier
+	for id, err := range s.Iterate(nil) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	if !slices.Equal(ids, []core.Identifier{b}) {
+		t.Fatalf("got %v", ids)
+	}
+}
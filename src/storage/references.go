@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"reflect"
+	"unsafe"
+
+	"github.com/ArborDB/arbordb/src/core"
+)
+
+var referenceType = reflect.TypeFor[core.Reference]()
+
+// References returns the identifiers referred to by the core.Reference values
+// in expr, like Refs and DictRefs, which refer to other stored objects.
+func References(expr core.Expression) []core.Identifier {
+	var ids []core.Identifier
+	visited := make(map[unsafe.Pointer]bool)
+	var walk func(v reflect.Value)
+	walk = func(v reflect.Value) {
+		if v.Kind() != reflect.Pointer && v.Kind() != reflect.Interface && v.Type().Implements(referenceType) {
+			if !v.CanInterface() {
+				// fields may not be exported to reflection
+				if !v.CanAddr() {
+					return
+				}
+				v = reflect.NewAt(v.Type(), unsafe.Pointer(v.UnsafeAddr())).Elem()
+			}
+			if id := v.Interface().(core.Reference).Referenced(); id.Key != "" {
+				ids = append(ids, id)
+			}
+			return
+		}
+		switch v.Kind() {
+		case reflect.Pointer:
+			if v.IsNil() || visited[v.UnsafePointer()] {
+				return
+			}
+			visited[v.UnsafePointer()] = true
+			walk(v.Elem())
+		case reflect.Interface:
+			if !v.IsNil() {
+				walk(v.Elem())
+			}
+		case reflect.Struct:
+			for i := range v.NumField() {
+				// caches of loaded objects
+				if v.Type().Field(i).Tag.Get("dshash") == "-" {
+					continue
+				}
+				walk(v.Field(i))
+			}
+		case reflect.Slice, reflect.Array:
+			switch v.Type().Elem().Kind() {
+			case reflect.Pointer, reflect.Interface, reflect.Struct, reflect.Slice, reflect.Array, reflect.Map:
+			default:
+				return
+			}
+			for i := range v.Len() {
+				walk(v.Index(i))
+			}
+		case reflect.Map:
+			iter := v.MapRange()
+			for iter.Next() {
+				walk(iter.Key())
+				walk(iter.Value())
+			}
+		}
+	}
+	if expr != nil {
+		// an addressable copy, to read the references in unexported fields
+		value := reflect.New(reflect.TypeOf(expr)).Elem()
+		value.Set(reflect.ValueOf(expr))
+		walk(value)
+	}
+	return ids
+}
@@ -0,0 +1,177 @@
+package storage
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/ArborDB/arbordb/src/core"
+)
+
+type ProblemKind int
+
+const (
+	// the root cannot be loaded
+	ProblemMissing ProblemKind = iota + 1
+	// an object does not hash to its identifier
+	ProblemMismatch
+	// an object refers to an object that cannot be loaded
+	ProblemDangling
+)
+
+// Problem is an integrity problem of a stored object.
+type Problem struct {
+	Kind ProblemKind
+	ID   core.Identifier
+	// the object referring to ID, zero for the root
+	Referrer core.Identifier
+	Err      error
+}
+
+func (p Problem) String() string {
+	switch p.Kind {
+	case ProblemMissing:
+		return fmt.Sprintf("missing root %v: %v", p.ID, p.Err)
+	case ProblemMismatch:
+		return fmt.Sprintf("mismatched object %v: %v", p.ID, p.Err)
+	}
+	return fmt.Sprintf("dangling reference from %v to %v: %v", p.Referrer, p.ID, p.Err)
+}
+
+// Verifier checks the integrity of the objects reachable from roots, see
+// References. It remembers the objects verified and their references, so
+// verifying a later root only loads and hashes the objects added since, and
+// checks that the others are still stored. It is safe for concurrent use.
+type Verifier struct {
+	storage core.PhysicalStorage
+	mu      sync.Mutex
+	// references of the verified objects
+	verified map[core.Identifier][]core.Identifier
+}
+
+func NewVerifier(storage core.PhysicalStorage) *Verifier {
+	return &Verifier{
+		storage:  storage,
+		verified: make(map[core.Identifier][]core.Identifier),
+	}
+}
+
+// Verify checks the objects reachable from a root with Verifier.Verify.
+func Verify(ctx *core.Context, storage core.PhysicalStorage, root core.Identifier) ([]Problem, error) {
+	return NewVerifier(storage).Verify(ctx, root)
+}
+
+// Verify loads the objects reachable from root not verified before, checks
+// that the ones with identifiers of IdentifierKind hash to them, and returns
+// the problems found. Objects verified before are checked with Has, so that
+// deleted objects are found. Objects with problems, and the objects reaching
+// them, are checked again by later calls.
+//
+// ctx.Yield is called before each object, so verifications running in the
+// background can be throttled or stopped by ctx.YieldFunc.
+func (v *Verifier) Verify(ctx *core.Context, root core.Identifier) ([]Problem, error) {
+	type pending struct {
+		id       core.Identifier
+		referrer core.Identifier
+	}
+	var problems []Problem
+	stack := []pending{{id: root}}
+	queued := map[core.Identifier]bool{root: true}
+	referrers := make(map[core.Identifier][]core.Identifier)
+	push := func(referrer core.Identifier, refs []core.Identifier) {
+		for _, ref := range refs {
+			referrers[ref] = append(referrers[ref], referrer)
+			if !queued[ref] {
+				queued[ref] = true
+				stack = append(stack, pending{id: ref, referrer: referrer})
+			}
+		}
+	}
+	for len(stack) > 0 {
+		if err := ctx.Yield(); err != nil {
+			return problems, err
+		}
+		next := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if next.id.Key == "" {
+			continue
+		}
+		if refs, ok := v.verifiedRefs(next.id); ok {
+			stored, err := v.storage.Has(ctx, next.id)
+			if err != nil {
+				return problems, err
+			}
+			if stored {
+				push(next.id, refs)
+				continue
+			}
+			// deleted since verified, reported by loading it
+		}
+
+		var expr core.Expression
+		if err := v.storage.Get(ctx, next.id, &expr); err != nil {
+			kind := ProblemDangling
+			if next.referrer == (core.Identifier{}) {
+				kind = ProblemMissing
+			}
+			problems = append(problems, Problem{
+				Kind:     kind,
+				ID:       next.id,
+				Referrer: next.referrer,
+				Err:      err,
+			})
+			continue
+		}
+
+		valid := true
+		if next.id.Kind == IdentifierKind {
+			id, err := Identify(expr)
+			if err == nil && id != next.id {
+				err = fmt.Errorf("hashes to %v", id)
+			}
+			if err != nil {
+				valid = false
+				problems = append(problems, Problem{
+					Kind:     ProblemMismatch,
+					ID:       next.id,
+					Referrer: next.referrer,
+					Err:      err,
+				})
+			}
+		}
+		refs := References(expr)
+		if valid {
+			v.mu.Lock()
+			v.verified[next.id] = refs
+			v.mu.Unlock()
+		}
+
+		push(next.id, refs)
+	}
+
+	// objects reaching problems are checked again
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	unmarked := make(map[core.Identifier]bool)
+	var unmark func(id core.Identifier)
+	unmark = func(id core.Identifier) {
+		if unmarked[id] {
+			return
+		}
+		unmarked[id] = true
+		delete(v.verified, id)
+		for _, referrer := range referrers[id] {
+			unmark(referrer)
+		}
+	}
+	for _, problem := range problems {
+		unmark(problem.ID)
+	}
+	return problems, nil
+}
+
+func (v *Verifier) verifiedRefs(id core.Identifier) ([]core.Identifier, bool) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	refs, ok := v.verified[id]
+	return refs, ok
+}
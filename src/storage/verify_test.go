@@ -0,0 +1,155 @@
+package storage
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/ArborDB/arbordb/src/core"
+)
+
+// node is an object referring to others
+type node struct {
+	Name     string
+	Children []core.Ref[core.Expression]
+}
+
+func (n node) String() string {
+	return n.Name
+}
+
+func refsTo(ids ...core.Identifier) []core.Ref[core.Expression] {
+	var refs []core.Ref[core.Expression]
+	for _, id := range ids {
+		refs = append(refs, core.NewRef[core.Expression](id))
+	}
+	return refs
+}
+
+func set(t *testing.T, m *Memory, expr core.Expression) core.Identifier {
+	t.Helper()
+	id, err := m.Set(nil, expr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func problemStrings(problems []Problem) []string {
+	var ret []string
+	for _, problem := range problems {
+		ret = append(ret, problem.String())
+	}
+	slices.Sort(ret)
+	return ret
+}
+
+func TestReferences(t *testing.T) {
+	a := core.Identifier{Kind: "k", Key: "a"}
+	b := core.Identifier{Kind: "k", Key: "b"}
+	c := core.Identifier{Kind: "k", Key: "c"}
+	expr := struct {
+		node
+		Map   map[string]core.Expression
+		ref   core.Ref[core.Expression]
+		Cache core.Ref[core.Expression] `dshash:"-"`
+		// identifiers alone are values
+		ID core.Identifier
+	}{
+		node:  node{Children: refsTo(a)},
+		Map:   map[string]core.Expression{"b": core.NewRef[core.Expression](b), "c": c},
+		ref:   core.NewRef[core.Expression](c),
+		Cache: core.NewRef[core.Expression](a),
+		ID:    b,
+	}
+	if got := References(expr); !slices.Equal(got, []core.Identifier{a, b, c}) {
+		t.Fatalf("got %v", got)
+	}
+
+	// loaded Refs refer to their object only
+	m := NewMemory()
+	ref, err := core.StoreRef[core.Expression](&core.Context{PhysicalStorage: m}, node{Children: refsTo(a)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := References(ref); !slices.Equal(got, []core.Identifier{ref.ID}) {
+		t.Fatalf("got %v", got)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	m := NewMemory()
+	leaf := set(t, m, node{Name: "leaf"})
+	other := set(t, m, node{Name: "other"})
+	mid := set(t, m, node{Name: "mid", Children: refsTo(leaf)})
+	root := set(t, m, node{Name: "root", Children: refsTo(mid, other)})
+
+	v := NewVerifier(m)
+	problems, err := v.Verify(nil, root)
+	if err != nil || len(problems) != 0 {
+		t.Fatalf("got %v, %v", problems, err)
+	}
+	if len(v.verified) != 4 {
+		t.Fatalf("verified %d objects", len(v.verified))
+	}
+
+	// verified objects are not checked again
+	m.items[other] = node{Name: "changed"}
+	newRoot := set(t, m, node{Name: "new root", Children: refsTo(root)})
+	if problems, err := v.Verify(nil, newRoot); err != nil || len(problems) != 0 {
+		t.Fatalf("got %v, %v", problems, err)
+	}
+
+	// objects deleted since verified are found
+	delete(m.items, leaf)
+	problems, err = v.Verify(nil, newRoot)
+	if err != nil || len(problems) != 1 || problems[0].Kind != ProblemDangling || problems[0].ID != leaf {
+		t.Fatalf("got %v, %v", problems, err)
+	}
+	if _, ok := v.verified[mid]; ok {
+		t.Fatal("object reaching a deleted object still verified")
+	}
+
+	// problems of a new verifier
+	problems, err = Verify(nil, m, newRoot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, problem := range problems {
+		if problem.Kind == ProblemDangling {
+			if !errors.Is(problem.Err, core.ErrNotFound{}) {
+				t.Fatalf("got %v", problem.Err)
+			}
+			problems[i].Err = nil
+		}
+	}
+	want := []string{
+		"dangling reference from " + mid.String() + " to " + leaf.String() + ": <nil>",
+		"mismatched object " + other.String() + ": hashes to " + set(t, m, node{Name: "changed"}).String(),
+	}
+	if got := problemStrings(problems); !slices.Equal(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	if problems, err := Verify(nil, m, core.Identifier{Kind: IdentifierKind, Key: "missing"}); err != nil || len(problems) != 1 || problems[0].Kind != ProblemMissing {
+		t.Fatalf("got %v, %v", problems, err)
+	}
+
+	// objects reaching problems are checked again
+	v = NewVerifier(m)
+	if problems, _ := v.Verify(nil, newRoot); len(problems) != 2 {
+		t.Fatalf("got %v", problems)
+	}
+	m.items[leaf] = node{Name: "leaf"}
+	m.items[other] = node{Name: "other"}
+	if problems, err := v.Verify(nil, newRoot); err != nil || len(problems) != 0 {
+		t.Fatalf("got %v, %v", problems, err)
+	}
+
+	// verification can be stopped
+	ctx := &core.Context{
+		YieldFunc: func() bool { return false },
+	}
+	if _, err := NewVerifier(m).Verify(ctx, newRoot); err == nil {
+		t.Fatal("expected canceled")
+	}
+}